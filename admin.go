@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Environment variable holding the password of the admin pages of tenant serve, which are
+// disabled without one
+const tenantAdminPasswordEnv = "SPOTIFY_TENANT_ADMIN_PASSWORD"
+
+// How far back the failed runs of a tenant are counted on the admin pages
+const adminErrorWindow = 30 * 24 * time.Hour
+
+// Runs listed on the page of a tenant
+const adminRecentRuns = 20
+
+// A tenant as shown on the admin pages
+type tenantView struct {
+	tenant
+	Status   string
+	Schedule string
+	LastSync string
+	// Outcome of the latest run, its error when it failed
+	LastRun   string
+	LastError string
+	// Failed runs within the error window and tracks added by every run
+	RecentErrors int
+	TracksAdded  int
+	// This period's playlist
+	CurrentName string
+	CurrentURL  string
+	// Every managed playlist and the latest runs, newest first, on the tenant's page
+	Playlists []playlistLink
+	Runs      []runSummary
+}
+
+type playlistLink struct {
+	Name string
+	URL  string
+}
+
+type adminPageData struct {
+	Title   string
+	Tenants []tenantView
+	Tenant  tenantView
+}
+
+const adminPageTemplates = `{{define "admin"}}{{template "head" .}}
+<body class="wide">
+<h1>{{.Title}}</h1>
+<table>
+<tr><th>Tenant</th><th>Status</th><th>Schedule</th><th>Last sync</th><th>Last run</th><th>Errors (30 days)</th><th>Tracks added</th><th>This period</th><th></th></tr>
+{{range .Tenants}}<tr>
+<td><a href="/admin/tenants/{{.ID}}">{{.ID}}</a>{{if .Name}}<br>{{.Name}}{{end}}</td>
+<td>{{.Status}}</td>
+<td>{{.Schedule}}</td>
+<td>{{.LastSync}}</td>
+<td>{{.LastRun}}{{if .LastError}}<br><span class="error">{{.LastError}}</span>{{end}}</td>
+<td>{{.RecentErrors}}</td>
+<td>{{.TracksAdded}}</td>
+<td>{{if .CurrentURL}}<a href="{{.CurrentURL}}">{{.CurrentName}}</a>{{end}}</td>
+<td>{{template "admin-actions" .}}</td>
+</tr>
+{{else}}<tr><td colspan="9">No tenants yet; add one with spotify-cli tenant add.</td></tr>
+{{end}}</table>
+</body>
+</html>
+{{end}}
+{{define "admin-tenant"}}{{template "head" .}}
+<body class="wide">
+<p><a href="/admin">All tenants</a></p>
+<h1>{{.Title}}</h1>
+{{with .Tenant}}
+<p>{{.Status}}; schedule {{.Schedule}}; last sync {{.LastSync}}; {{.TracksAdded}} track(s) added, {{.RecentErrors}} failed run(s) in the last 30 days.</p>
+<p>{{template "admin-actions" .}}</p>
+<h2>Playlists</h2>
+<ul>
+{{range .Playlists}}<li><a href="{{.URL}}">{{.Name}}</a></li>
+{{else}}<li>None yet.</li>
+{{end}}</ul>
+<h2>Recent runs</h2>
+<table>
+<tr><th>Started</th><th>Run</th><th>Tracks added</th><th>Playlists</th><th>Error</th></tr>
+{{range .Runs}}<tr>
+<td>{{.StartedAt.Format "2006-01-02 15:04"}}</td>
+<td>{{.RunID}}{{if .Partial}} (partial){{end}}</td>
+<td>{{.TracksAdded}}</td>
+<td>{{range .Playlists}}<a href="{{.URL}}">{{.Name}}</a> +{{.TracksAdded}}<br>{{end}}</td>
+<td class="error">{{.Error}}</td>
+</tr>
+{{else}}<tr><td colspan="5">No runs yet.</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+{{end}}
+{{define "admin-actions"}}{{if eq .Status "paused"}}<form class="inline" method="post" action="/admin/tenants/{{.ID}}/resume"><button>Resume</button></form>
+{{else if eq .Status "active"}}<form class="inline" method="post" action="/admin/tenants/{{.ID}}/pause"><button>Pause</button></form>
+{{end}}<form class="inline" method="post" action="/admin/tenants/{{.ID}}/remove" onsubmit="return confirm('Remove {{.ID}} and all of their data? Their playlists stay in Spotify.')"><button class="danger">Remove</button></form>{{end}}
+`
+
+// Function to get the status of a tenant: pending until onboarded, then active or paused
+func (t tenant) status() string {
+	switch {
+	case !t.onboarded():
+		return "pending"
+	case t.Paused:
+		return "paused"
+	}
+	return "active"
+}
+
+// Function to describe the schedule of a tenant
+func (t tenant) scheduleText() string {
+	switch {
+	case t.Cron != "":
+		return "cron " + t.Cron
+	case t.Every != "":
+		return "every " + t.Every
+	}
+	return "default"
+}
+
+// Function to gather what the admin pages show of a tenant from its state and run history
+func viewTenant(t tenant, detailed bool) tenantView {
+	view := tenantView{tenant: t, Status: t.status(), Schedule: t.scheduleText(), LastSync: "never", LastRun: "never"}
+
+	state, err := loadState(t.path("state.json"))
+	if err != nil {
+		slog.Warn("Could not read the state of the tenant", "tenant", t.ID, "error", err)
+	}
+	view.LastSync = formatStateTime(state.LastSyncedAt)
+	current := periodPlaylistName(nowInMonthZone())
+	if id := state.Playlists[current]; id != "" {
+		view.CurrentName, view.CurrentURL = current, playlistURL(id)
+	}
+
+	history, err := loadRunHistory(t.path("history.jsonl"))
+	if err != nil {
+		slog.Warn("Could not read the run history of the tenant", "tenant", t.ID, "error", err)
+	}
+	for _, run := range history {
+		view.TracksAdded += run.TracksAdded
+		if run.Error != "" && time.Since(run.StartedAt) < adminErrorWindow {
+			view.RecentErrors++
+		}
+	}
+	if len(history) > 0 {
+		last := history[len(history)-1]
+		view.LastRun, view.LastError = last.StartedAt.Format(time.RFC3339), last.Error
+	}
+
+	if detailed {
+		for _, name := range playlistNames(state) {
+			view.Playlists = append(view.Playlists, playlistLink{Name: name, URL: playlistURL(state.Playlists[name])})
+		}
+		for i := len(history) - 1; i >= 0 && len(view.Runs) < adminRecentRuns; i-- {
+			view.Runs = append(view.Runs, history[i])
+		}
+	}
+	return view
+}
+
+// Function to require the admin password, as the password of HTTP basic authentication, and
+// for the POST requests a same-origin form, since the browser resends the credentials anywhere
+func (s *onboardingServer) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminPassword == "" {
+			http.NotFound(w, r)
+			return
+		}
+		_, password, _ := r.BasicAuth()
+		if subtle.ConstantTimeCompare([]byte(password), []byte(s.adminPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="spotify-cli admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost {
+			origin, err := url.Parse(r.Header.Get("Origin"))
+			if r.Header.Get("Origin") != "" && (err != nil || origin.Host != r.Host) {
+				http.Error(w, "cross-origin request", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (s *onboardingServer) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	tenants, err := listTenants()
+	if err != nil {
+		slog.Error("Could not list the tenants", "error", err)
+		http.Error(w, "could not list the tenants", http.StatusInternalServerError)
+		return
+	}
+	data := adminPageData{Title: "Tenants"}
+	for _, t := range tenants {
+		data.Tenants = append(data.Tenants, viewTenant(t, false))
+	}
+	renderPage(w, http.StatusOK, "admin", data)
+}
+
+func (s *onboardingServer) handleAdminTenant(w http.ResponseWriter, r *http.Request) {
+	t, err := loadTenant(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	title := t.ID
+	if t.Name != "" {
+		title += " (" + t.Name + ")"
+	}
+	renderPage(w, http.StatusOK, "admin-tenant", adminPageData{Title: title, Tenant: viewTenant(t, true)})
+}
+
+// Function to pause, resume or remove a tenant from the admin pages
+func (s *onboardingServer) handleAdminAction(w http.ResponseWriter, r *http.Request) {
+	t, err := loadTenant(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch action := r.PathValue("action"); action {
+	case "pause", "resume":
+		t.Paused = action == "pause"
+		err = saveTenant(t)
+	case "remove":
+		err = os.RemoveAll(t.path(""))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("Could not change the tenant", "tenant", t.ID, "action", r.PathValue("action"), "error", err)
+		http.Error(w, "the change could not be saved", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Changed the tenant from the admin page", "tenant", t.ID, "action", r.PathValue("action"))
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eduardohitek/spotify-cli/spotify"
+)
+
+// Function to register the monthly playlists created before the tool kept any state, so
+// upgrading doesn't create duplicates of them
+func runAdopt(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("adopt", flag.ExitOnError)
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping the state between runs")
+	flags.Parse(args)
+
+	if err := adoptPlaylists(ctx, *statePath); err != nil {
+		fmt.Println("Error adopting playlists:", err)
+	}
+}
+
+// A playlist found under a monthly name
+type adoptedPlaylist struct {
+	playlist spotify.Playlist
+	month    time.Time
+	skipped  bool
+}
+
+// Function to find the user's playlists named after a month (and their skipped companions),
+// compare each one with the songs liked in its month and record them in the state.
+// Playlists already in the state are left as they are. With --dry-run nothing is saved.
+func adoptPlaylists(ctx context.Context, statePath string) error {
+	if syncPeriod != periodMonthly {
+		return fmt.Errorf("only monthly playlists can be adopted, not %s ones", syncPeriod)
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	accessToken := token.AccessToken
+
+	playlists, err := allPlaylists(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("listing playlists: %w", err)
+	}
+	found := map[string]adoptedPlaylist{}
+	var first time.Time
+	for _, playlist := range playlists {
+		month, skipped, ok := parseMonthlyPlaylistName(playlist.Name)
+		if !ok {
+			continue
+		}
+		name := periodPlaylistName(month)
+		if skipped {
+			name = skippedPlaylistName(name)
+		}
+		if _, managed := state.Playlists[name]; managed {
+			continue
+		}
+		if other, ok := found[name]; ok {
+			slog.WarnContext(ctx, "Two playlists have the same name; adopting the first one", "playlist", name, "playlist_id", other.playlist.ID, "other_playlist_id", playlist.ID)
+			continue
+		}
+		found[name] = adoptedPlaylist{playlist: playlist, month: month, skipped: skipped}
+		if first.IsZero() || month.Before(first) {
+			first = month
+		}
+	}
+	if len(found) == 0 {
+		fmt.Println("No monthly playlists to adopt.")
+		return nil
+	}
+
+	liked, err := fetchLikedSongs(ctx, accessToken, first)
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	byMonth := map[time.Time][]Track{}
+	for _, song := range liked.Items {
+		month := monthStart(song.AddedAt.In(monthZone))
+		byMonth[month] = append(byMonth[month], song.Track)
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		adopted := found[name]
+		if adopted.skipped {
+			fmt.Printf("%s: adopting %s.\n", name, adopted.playlist.ID)
+		} else {
+			missing, err := missingFromPlaylist(ctx, accessToken, adopted.playlist.ID, byMonth[adopted.month])
+			if err != nil {
+				return fmt.Errorf("reading playlist %s: %w", name, err)
+			}
+			fmt.Printf("%s: adopting %s; %d of %d song(s) liked that month are missing from it.\n",
+				name, adopted.playlist.ID, len(missing), len(byMonth[adopted.month]))
+		}
+		state.setPlaylist(name, adopted.playlist.ID)
+	}
+
+	if dryRun {
+		fmt.Printf("Dry run: would adopt %d playlist(s).\n", len(names))
+		return nil
+	}
+	if err := saveState(statePath, state); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	fmt.Printf("Adopted %d playlist(s); run backfill to add the missing songs.\n", len(names))
+	return nil
+}
+
+// Function to list all the playlists the user owns or follows
+func allPlaylists(ctx context.Context, accessToken string) ([]spotify.Playlist, error) {
+	var playlists []spotify.Playlist
+	client := apiClient(accessToken)
+	for offset := 0; ; {
+		var page spotify.Page[spotify.Playlist]
+		err := fetchPage("/me/playlists", func(limit int) (err error) {
+			page, err = client.Playlists(ctx, spotify.PageOptions{Limit: limit, Offset: offset})
+			return err
+		})
+		if err != nil {
+			return playlists, err
+		}
+		playlists = append(playlists, page.Items...)
+		offset += len(page.Items)
+		if page.Next == "" || len(page.Items) == 0 {
+			return playlists, nil
+		}
+	}
+}
+
+// Function to get the month a monthly playlist name (e.g. Jan'24, with the default --name-format) stands for, and whether it
+// names the skipped companion playlist
+func parseMonthlyPlaylistName(name string) (time.Time, bool, bool) {
+	name = strings.TrimSpace(typographicReplacer.Replace(name))
+	base, skipped := strings.CutSuffix(name, typographicReplacer.Replace(skippedPlaylistName("")))
+	month, err := time.ParseInLocation(playlistNameFormat, strings.TrimSpace(base), monthZone)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return month, skipped, true
+}
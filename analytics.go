@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Tables of the SQLite analytics cache, replaced by every cache warm --sqlite, and the views over
+// them. The likes are dated in the --timezone zone, so their months match the playlists'.
+const analyticsSchema = `
+CREATE TABLE IF NOT EXISTS liked_songs (
+	track_id TEXT PRIMARY KEY,
+	added_at TEXT NOT NULL,
+	name TEXT NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	explicit INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS track_artists (
+	track_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	artist_id TEXT NOT NULL,
+	artist_name TEXT NOT NULL,
+	PRIMARY KEY (track_id, position)
+);
+CREATE TABLE IF NOT EXISTS artist_genres (
+	artist_id TEXT NOT NULL,
+	genre TEXT NOT NULL,
+	PRIMARY KEY (artist_id, genre)
+);
+
+DROP VIEW IF EXISTS likes_per_month;
+CREATE VIEW likes_per_month AS
+	SELECT substr(added_at, 1, 7) AS month, count(*) AS likes
+	FROM liked_songs GROUP BY month ORDER BY month;
+
+DROP VIEW IF EXISTS top_artists;
+CREATE VIEW top_artists AS
+	SELECT artist_name AS artist, count(DISTINCT track_id) AS likes
+	FROM track_artists GROUP BY artist_id ORDER BY likes DESC, artist;
+
+DROP VIEW IF EXISTS genre_share;
+CREATE VIEW genre_share AS
+	SELECT genre, count(DISTINCT track_id) AS likes,
+		round(100.0 * count(DISTINCT track_id) / (SELECT count(*) FROM liked_songs), 1) AS percent
+	FROM track_artists JOIN artist_genres USING (artist_id)
+	GROUP BY genre ORDER BY likes DESC, genre;
+`
+
+// Predefined queries of the query subcommand, one per analytics view
+var analyticsQueries = map[string]string{
+	"likes_per_month": "SELECT * FROM likes_per_month",
+	"top_artists":     "SELECT * FROM top_artists LIMIT 25",
+	"genre_share":     "SELECT * FROM genre_share",
+}
+
+// Function to get the default location of the analytics cache, next to the state file
+func defaultAnalyticsPath() string {
+	return filepath.Join(filepath.Dir(defaultStatePath()), "library.db")
+}
+
+// Function to replace the contents of the analytics cache with the liked library, the genres
+// coming from the artists of the track cache
+func writeAnalytics(ctx context.Context, path string, liked []LikedSong, cache *trackCache) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, analyticsSchema); err != nil {
+		return fmt.Errorf("creating the tables: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"liked_songs", "track_artists", "artist_genres"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return fmt.Errorf("emptying %s: %w", table, err)
+		}
+	}
+	genres := map[string]bool{}
+	for _, song := range liked {
+		track := song.Track
+		if track.ID == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO liked_songs VALUES (?, ?, ?, ?, ?)",
+			track.ID, song.AddedAt.In(monthZone).Format(time.RFC3339), track.Name, track.DurationMs, track.Explicit); err != nil {
+			return fmt.Errorf("adding %s: %w", track.ID, err)
+		}
+		for i, artist := range track.Artists {
+			if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO track_artists VALUES (?, ?, ?, ?)", track.ID, i, artist.ID, artist.Name); err != nil {
+				return fmt.Errorf("adding the artists of %s: %w", track.ID, err)
+			}
+			cached, ok := cache.Artists[artist.ID]
+			if !ok || genres[artist.ID] {
+				continue
+			}
+			genres[artist.ID] = true
+			for _, genre := range cached.Artist.Genres {
+				if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO artist_genres VALUES (?, ?)", artist.ID, genre); err != nil {
+					return fmt.Errorf("adding the genres of %s: %w", artist.ID, err)
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Function to run a predefined or ad-hoc SQL query over the analytics cache
+func runQuery(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	dbPath := flags.String("sqlite-file", defaultAnalyticsPath(), "SQLite analytics cache, filled by cache warm --sqlite")
+	format := flags.String("format", "table", "output format: "+formatNames())
+	tmpl := flags.String("template", "", "Go template used by --format template")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		names := make([]string, 0, len(analyticsQueries))
+		for name := range analyticsQueries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("Usage: spotify-cli query [flags] %s|<SQL>\n", strings.Join(names, "|"))
+		os.Exit(2)
+	}
+	query := strings.Join(flags.Args(), " ")
+	if predefined, ok := analyticsQueries[query]; ok {
+		query = predefined
+	}
+
+	r, err := queryAnalytics(ctx, *dbPath, query)
+	if err != nil {
+		fmt.Println("Error querying the analytics cache:", err)
+		return
+	}
+	if err := writeReport(os.Stdout, *format, *tmpl, r); err != nil {
+		fmt.Println("Error writing results:", err)
+	}
+}
+
+// Function to run a query over the analytics cache, opened read-only, into a report of its rows
+func queryAnalytics(ctx context.Context, path, query string) (report, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return report{}, fmt.Errorf("no analytics cache at %s; fill it with cache warm --sqlite", path)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return report{}, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return report{}, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return report{}, err
+	}
+
+	r := report{Columns: make([]string, len(columns))}
+	for i, column := range columns {
+		r.Columns[i] = strings.ToUpper(column)
+	}
+	data := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return report{}, err
+		}
+		row := make([]string, len(columns))
+		item := map[string]any{}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			if value != nil {
+				row[i] = fmt.Sprint(value)
+			}
+			item[columns[i]] = value
+		}
+		r.Rows = append(r.Rows, row)
+		data = append(data, item)
+	}
+	r.Data = data
+	return r, rows.Err()
+}
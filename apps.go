@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// A Spotify app (client ID/secret pair) with the refresh token authorized for it
+type spotifyApp struct {
+	Name            string
+	ClientID        string
+	ClientSecret    string
+	RefreshToken    string
+	RefreshTokenEnv string
+	// Tenant whose refresh token the app holds, in the multi-tenant mode, and where a rotated
+	// refresh token of the tenant is saved
+	tenant           string
+	saveRefreshToken func(refreshToken string) error
+
+	// Held while the app gets an access token, so concurrent requests refresh it once
+	refreshMu   sync.Mutex
+	accessToken string
+	// Whether the access token came from the token cache rather than a refresh in this run
+	cached   bool
+	requests int
+	failures int
+	disabled bool
+}
+
+// Function to load the primary app and, when configured, the secondary app used for failover
+func loadApps() []*spotifyApp {
+	apps := []*spotifyApp{{
+		Name:            "primary",
+		ClientID:        os.Getenv("SPOTIFY_CLIENT_ID"),
+		ClientSecret:    os.Getenv("SPOTIFY_CLIENT_SECRET"),
+		RefreshToken:    os.Getenv("SPOTIFY_REFRESH_TOKEN"),
+		RefreshTokenEnv: "SPOTIFY_REFRESH_TOKEN",
+	}}
+	if os.Getenv("SPOTIFY_SECONDARY_CLIENT_ID") != "" {
+		apps = append(apps, &spotifyApp{
+			Name:            "secondary",
+			ClientID:        os.Getenv("SPOTIFY_SECONDARY_CLIENT_ID"),
+			ClientSecret:    os.Getenv("SPOTIFY_SECONDARY_CLIENT_SECRET"),
+			RefreshToken:    os.Getenv("SPOTIFY_SECONDARY_REFRESH_TOKEN"),
+			RefreshTokenEnv: "SPOTIFY_SECONDARY_REFRESH_TOKEN",
+		})
+	}
+	return apps
+}
+
+// A pool of Spotify apps used as the transport of the Web API calls.
+// It authorizes each request with the active app and fails over to the next app
+// when the active one is rate-limited or returns server errors. Its lock is never held
+// over a token request, which would stall the concurrent requests.
+type appPool struct {
+	mu      sync.Mutex
+	apps    []*spotifyApp
+	current int
+	next    http.RoundTripper
+	// Held while failing over, so concurrent failing requests fail over once
+	failoverMu sync.Mutex
+	// Access token the run authenticated with, keying its client in runClients
+	runToken string
+}
+
+func newAppPool(apps []*spotifyApp, next http.RoundTripper) *appPool {
+	return &appPool{apps: apps, next: next}
+}
+
+// Function to get an access token for the first app that can be authenticated
+func (p *appPool) authenticate(ctx context.Context) (AccessTokenResponse, error) {
+	var lastErr error
+	for i := range p.apps {
+		token, err := p.authenticateApp(ctx, p.apps[i])
+		if err != nil {
+			slog.WarnContext(ctx, "Could not authenticate the Spotify app", "app", p.apps[i].Name, "error", err)
+			p.disable(p.apps[i])
+			lastErr = err
+			continue
+		}
+		p.mu.Lock()
+		p.current = i
+		p.mu.Unlock()
+		return token, nil
+	}
+	return AccessTokenResponse{}, lastErr
+}
+
+// Function to get an access token for the app, from the token cache while it's valid
+func (p *appPool) authenticateApp(ctx context.Context, app *spotifyApp) (AccessTokenResponse, error) {
+	app.refreshMu.Lock()
+	defer app.refreshMu.Unlock()
+	if token, ok := cachedAccessToken(app); ok {
+		p.mu.Lock()
+		app.accessToken, app.cached = token.AccessToken, true
+		p.mu.Unlock()
+		return token, nil
+	}
+	return p.refreshApp(ctx, app)
+}
+
+// Function to refresh the access token of the app that a request got rejected, once for all
+// the requests that got it rejected at the same time
+func (p *appPool) refreshRejected(ctx context.Context, app *spotifyApp, rejected string) error {
+	app.refreshMu.Lock()
+	defer app.refreshMu.Unlock()
+	p.mu.Lock()
+	refreshed := app.accessToken != rejected
+	p.mu.Unlock()
+	if refreshed {
+		return nil
+	}
+	_, err := p.refreshApp(ctx, app)
+	return err
+}
+
+// Function to get a new access token for the app with its refresh token, with its refreshMu held
+func (p *appPool) refreshApp(ctx context.Context, app *spotifyApp) (AccessTokenResponse, error) {
+	obtainedAt := time.Now()
+	token, err := getAccessToken(ctx, app.ClientID, app.ClientSecret, app.RefreshToken)
+	if err != nil {
+		return token, err
+	}
+	if token.AccessToken == "" {
+		return token, errors.New("the refresh token was rejected")
+	}
+	p.mu.Lock()
+	app.accessToken, app.cached = token.AccessToken, false
+	p.mu.Unlock()
+
+	if token.RefreshToken != "" && token.RefreshToken != app.RefreshToken {
+		app.RefreshToken = token.RefreshToken
+		if app.saveRefreshToken != nil {
+			if err := app.saveRefreshToken(token.RefreshToken); err != nil {
+				slog.ErrorContext(ctx, "Spotify rotated the refresh token of the tenant but it could not be saved", "tenant", app.tenant, "error", err)
+			}
+		} else {
+			os.Setenv(app.RefreshTokenEnv, token.RefreshToken)
+			if err := persistRefreshToken(envFile, app.RefreshTokenEnv, token.RefreshToken); err != nil {
+				slog.ErrorContext(ctx, "Spotify rotated the refresh token but it could not be saved; update it manually", "env", app.RefreshTokenEnv, "error", err)
+			} else {
+				slog.InfoContext(ctx, "Spotify rotated the refresh token; saved it", "path", envFile)
+			}
+		}
+	}
+	cacheAccessToken(app, token, obtainedAt)
+	return token, nil
+}
+
+// Function to fail over from the app to the next one, returning false when there's none left.
+// A request failing on an app another request already failed over from retries with the new one.
+func (p *appPool) failover(ctx context.Context, from *spotifyApp) bool {
+	p.failoverMu.Lock()
+	defer p.failoverMu.Unlock()
+
+	p.mu.Lock()
+	if p.apps[p.current] != from {
+		p.mu.Unlock()
+		return true
+	}
+	from.disabled = true
+	start := p.current + 1
+	p.mu.Unlock()
+
+	for i := start; i < len(p.apps); i++ {
+		if _, err := p.authenticateApp(ctx, p.apps[i]); err != nil {
+			slog.WarnContext(ctx, "Could not authenticate the Spotify app", "app", p.apps[i].Name, "error", err)
+			p.disable(p.apps[i])
+			continue
+		}
+		slog.WarnContext(ctx, "Failing over to another Spotify app", "app", p.apps[i].Name)
+		p.mu.Lock()
+		p.current = i
+		p.mu.Unlock()
+		return true
+	}
+	return false
+}
+
+func (p *appPool) disable(app *spotifyApp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	app.disabled = true
+}
+
+func (p *appPool) active() *spotifyApp {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.apps[p.current]
+}
+
+// Function to get the active app with its access token and whether it came from the cache
+func (p *appPool) activeToken() (*spotifyApp, string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	app := p.apps[p.current]
+	return app, app.accessToken, app.cached
+}
+
+func (p *appPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isAPIRequest(req.URL) {
+		return p.next.RoundTrip(req)
+	}
+
+	for {
+		app, accessToken, cached := p.activeToken()
+		attempt, err := rewindRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		attempt.Header.Set("Authorization", "Bearer "+accessToken)
+
+		p.mu.Lock()
+		app.requests++
+		p.mu.Unlock()
+
+		resp, err := p.next.RoundTrip(attempt)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && cached {
+			// The cached token may have been revoked before it expired
+			slog.InfoContext(req.Context(), "The cached access token of the Spotify app was rejected; refreshing it", "app", app.Name)
+			if err := p.refreshRejected(req.Context(), app, accessToken); err != nil {
+				return resp, nil
+			}
+			resp.Body.Close()
+			continue
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+
+		p.mu.Lock()
+		app.failures++
+		p.mu.Unlock()
+
+		slog.WarnContext(req.Context(), "The Spotify app got an error", "app", app.Name, "status", resp.StatusCode)
+		if !p.failover(req.Context(), app) {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+}
+
+// Function to log how many requests each app made, for accounting of heavy runs
+func (p *appPool) logUsage() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, app := range p.apps {
+		if app.requests > 0 || len(p.apps) > 1 {
+			slog.Info("Usage of the Spotify app", "app", app.Name, "requests", app.requests, "failures", app.failures)
+		}
+	}
+}
+
+// Function to end the run of the pool, logging its usage and forgetting its client
+func (p *appPool) release() {
+	p.logUsage()
+	forgetRunClient(p.runToken)
+}
+
+func isAPIRequest(u *url.URL) bool {
+	base, _ := url.Parse(baseAPIURL)
+	return u.Host == base.Host
+}
+
+// Function to copy a request so it can be sent again, rewinding its body
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	attempt := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attempt.Body = body
+	}
+	return attempt, nil
+}
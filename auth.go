@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const authorizeURL = "https://accounts.spotify.com/authorize"
+
+// Function to manage the Spotify authorization
+func runAuth(ctx context.Context, args []string) {
+	if len(args) == 0 || args[0] != "login" {
+		fmt.Println("Usage: spotify-cli auth login [flags]")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("auth login", flag.ExitOnError)
+	port := flags.Int("port", 8888, "local port of the callback; http://127.0.0.1:<port>/callback must be a redirect URI of the app")
+	secondary := flags.Bool("secondary", false, "authorize the secondary app used for failover")
+	noBrowser := flags.Bool("no-browser", false, "only print the authorization URL instead of opening it")
+	libraryWrite := flags.Bool("library-write", false, "also ask to change the liked songs, which like and unlike need")
+	readOnly := flags.Bool("read-only", false, "only ask to read the liked songs, which export and status need, rather than for what sync needs with the current settings")
+	flags.Parse(args[1:])
+
+	features := loginFeatures(*readOnly, *libraryWrite)
+
+	apps := loadApps()
+	app := apps[0]
+	if *secondary {
+		if len(apps) < 2 {
+			fmt.Println("Error: SPOTIFY_SECONDARY_CLIENT_ID is not set")
+			return
+		}
+		app = apps[1]
+	}
+	if app.ClientID == "" {
+		fmt.Printf("Error: set the client ID of the %s app in %s first\n", app.Name, envFile)
+		return
+	}
+	if app.ClientSecret == "" {
+		slog.Info("No client secret is set for the app; using the PKCE flow", "app", app.Name)
+	}
+
+	refreshToken, err := login(ctx, app, requiredScopes(features...), *port, !*noBrowser)
+	if err != nil {
+		fmt.Println("Error logging in:", err)
+		return
+	}
+	if err := persistRefreshToken(envFile, app.RefreshTokenEnv, refreshToken); err != nil {
+		fmt.Println("Error saving the refresh token:", err)
+		return
+	}
+	fmt.Printf("Logged in; saved %s to %s.\n", app.RefreshTokenEnv, envFile)
+}
+
+// Function to run the authorization code flow: send the user to Spotify's consent page, wait
+// for the redirect to the local callback and exchange its code for a refresh token. Apps without
+// a client secret use the PKCE extension, proving they started the flow with a code verifier.
+func login(ctx context.Context, app *spotifyApp, scopes []string, port int, openBrowser bool) (string, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(nonce)
+	verifier, err := pkceVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"client_id":     {app.ClientID},
+		"response_type": {"code"},
+		"redirect_uri":  {redirectURI},
+		"state":         {state},
+		"scope":         {strings.Join(scopes, " ")},
+	}
+	if app.ClientSecret == "" {
+		query.Set("code_challenge_method", "S256")
+		query.Set("code_challenge", pkceChallenge(verifier))
+	}
+	consentURL := authorizeURL + "?" + query.Encode()
+
+	codes := make(chan string, 1)
+	failures := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case r.URL.Query().Get("state") != state:
+			http.Error(w, "unexpected state", http.StatusBadRequest)
+			return
+		case r.URL.Query().Get("error") != "":
+			fmt.Fprintln(w, "Authorization denied; you can close this tab.")
+			select {
+			case failures <- fmt.Errorf("authorization denied: %s", r.URL.Query().Get("error")):
+			default:
+			}
+		default:
+			fmt.Fprintln(w, "Logged in; you can close this tab.")
+			select {
+			case codes <- r.URL.Query().Get("code"):
+			default:
+			}
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	fmt.Printf("Open this URL to authorize the %s app (%s must be one of its redirect URIs):\n%s\n", app.Name, redirectURI, consentURL)
+	if openBrowser {
+		if err := browse(consentURL); err != nil {
+			slog.Warn("Could not open the browser", "error", err)
+		}
+	}
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-failures:
+		return "", err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	token, err := exchangeAuthorizationCode(ctx, app.ClientID, app.ClientSecret, code, redirectURI, verifier)
+	if err != nil {
+		return "", err
+	}
+	if missing := missingScopes(token.Scope, scopes); len(missing) > 0 {
+		slog.Warn("The authorization is missing scopes", "scopes", strings.Join(missing, " "))
+	}
+	return token.RefreshToken, nil
+}
+
+// Function to exchange the code of an authorization for its tokens, with the code verifier
+// instead of the client secret when there's none
+func exchangeAuthorizationCode(ctx context.Context, clientID, clientSecret, code, redirectURI, verifier string) (AccessTokenResponse, error) {
+	var tokenResponse AccessTokenResponse
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURI}}
+	if clientSecret == "" {
+		form.Set("code_verifier", verifier)
+	}
+	req, err := newTokenRequest(ctx, clientID, clientSecret, form)
+	if err != nil {
+		return tokenResponse, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return tokenResponse, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return tokenResponse, fmt.Errorf("exchanging the code: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return tokenResponse, err
+	}
+	if tokenResponse.RefreshToken == "" {
+		return tokenResponse, errors.New("spotify returned no refresh token")
+	}
+	return tokenResponse, nil
+}
+
+// Function to build a request to the token endpoint, authenticating with the client secret
+// or, for PKCE apps, only naming the client
+func newTokenRequest(ctx context.Context, clientID, clientSecret string, form url.Values) (*http.Request, error) {
+	if clientSecret == "" {
+		form.Set("client_id", clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", refreshTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	if clientSecret != "" {
+		req.SetBasicAuth(clientID, clientSecret)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// Function to generate a PKCE code verifier: 64 random URL-safe characters
+func pkceVerifier() (string, error) {
+	random := make([]byte, 48)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// Function to derive the S256 code challenge sent with the authorization request
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Function to open a URL in the default browser
+func browse(target string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", target).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", target).Start()
+	}
+	return exec.Command("xdg-open", target).Start()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Function to create and fill the monthly playlists of past months from the whole liked library
+func runBackfill(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	from := flags.String("from", "", "first period to backfill, given by a month (YYYY-MM) or a day (YYYY-MM-DD) in it")
+	to := flags.String("to", "", "last period to backfill, given by a month or a day in it; the last finished period, as of --as-of, by default")
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	if *from == "" {
+		fmt.Println("Error: --from is required, e.g. --from 2023-01")
+		return
+	}
+	first, err := parsePeriodArg(*from)
+	if err != nil {
+		fmt.Println("Error parsing --from:", err)
+		return
+	}
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	last := previousPeriod(opts.Clock.Now())
+	if *to != "" {
+		if last, err = parsePeriodArg(*to); err != nil {
+			fmt.Println("Error parsing --to:", err)
+			return
+		}
+	}
+	if last.Before(first) {
+		fmt.Println("Error: --to is before --from")
+		return
+	}
+
+	perform := func(ctx context.Context, opts syncOptions, summary *runSummary) error {
+		return performBackfill(ctx, opts, first, last, summary)
+	}
+	if _, err := runWithHooks(ctx, opts, perform); err != nil {
+		fmt.Println("Error", err)
+	}
+}
+
+// Function to sync every period (month by default) from the one starting at last back to the one
+// starting at first, reading the liked library once, page by page, and grouping it by the period
+// each song was liked in. Playlists that already exist are filled, not duplicated.
+func performBackfill(ctx context.Context, opts syncOptions, first, last time.Time, summary *runSummary) error {
+	if opts.LockPath != "" {
+		lock, err := acquireLock(opts.LockPath, opts.LockTTL)
+		if err != nil {
+			return fmt.Errorf("acquiring lock: %w", err)
+		}
+		defer lock.Release()
+	}
+
+	state, err := loadState(opts.StatePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	defer saveTrackCache(opts.Cache)
+	accessToken := token.AccessToken
+	summary.Owner = runOwner(ctx, accessToken, apps)
+	if err := checkSyncScopes(token); err != nil {
+		return err
+	}
+
+	// The liked songs come newest first, so a period is complete once a song liked before it
+	// shows up. Each period is synced then and dropped, only one being held in memory at a time.
+	var (
+		period  time.Time
+		songs   []Track
+		read    int
+		syncErr error
+	)
+	// The periods from next on down haven't been synced or skipped yet
+	next := last
+	skipTo := func(until time.Time) {
+		for ; next.After(until); next = previousPeriod(next) {
+			slog.InfoContext(ctx, "No liked songs in the period; skipping it", "playlist", periodPlaylistName(next))
+		}
+	}
+	syncGathered := func() error {
+		if len(songs) == 0 {
+			return nil
+		}
+		skipTo(period)
+		syncErr = backfillPeriod(ctx, accessToken, period, songs, opts, &state, summary)
+		next, songs = previousPeriod(period), nil
+		return syncErr
+	}
+	err = eachLikedSongsPage(ctx, accessToken, first, func(page LikedSongsSearchResponse) error {
+		read += len(page.Items)
+		for _, song := range page.Items {
+			start := periodStart(song.AddedAt.In(monthZone))
+			if start.After(last) || start.Before(first) {
+				continue
+			}
+			if !start.Equal(period) {
+				if err := syncGathered(); err != nil {
+					return err
+				}
+				period = start
+			}
+			songs = append(songs, song.Track)
+		}
+		return nil
+	})
+	if syncErr != nil {
+		return syncErr
+	}
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	if err := syncGathered(); err != nil {
+		return err
+	}
+	skipTo(previousPeriod(first))
+	slog.InfoContext(ctx, "Read the liked songs", "songs", read, "since", first.Format("2006-01-02"))
+	return nil
+}
+
+// Function to sync the liked songs of one period into its playlist, saving the state after it
+func backfillPeriod(ctx context.Context, accessToken string, period time.Time, songs []Track, opts syncOptions, state *syncState, summary *runSummary) error {
+	result, err := syncTracks(ctx, accessToken, periodClock(period), songs, opts, state)
+	summary.add(result)
+	if opts.Pretty {
+		printSummary(os.Stdout, result, err, useColor(os.Stdout))
+	}
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		return nil
+	}
+	slog.InfoContext(ctx, "Backfilled the playlist", "playlist", result.PlaylistName, "playlist_id", result.PlaylistID, "tracks_added", len(result.Added))
+
+	// Save after every period so an interrupted backfill keeps the playlists it found or created
+	if err := saveState(opts.StatePath, *state); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eduardohitek/spotify-cli/spotify"
+)
+
+// Sizes of the generated libraries the benchmarks run over
+var benchmarkLibrarySizes = []int{10_000, 50_000}
+
+// Function to generate a library of n liked songs, newest first, liked an hour apart, with a
+// few hundred artists and some explicit and long tracks for the filters to reject
+func generateLibrary(n int) []spotify.SavedTrack {
+	newest := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	liked := make([]spotify.SavedTrack, n)
+	for i := range liked {
+		liked[i] = spotify.SavedTrack{
+			AddedAt: newest.Add(-time.Duration(i) * time.Hour),
+			Track: Track{
+				ID:          fmt.Sprintf("track%07d", i),
+				Name:        fmt.Sprintf("Song %d", i),
+				Artists:     []Artist{{ID: fmt.Sprintf("artist%03d", i%300), Name: fmt.Sprintf("Artist %d", i%300)}},
+				Explicit:    i%7 == 0,
+				DurationMs:  150_000 + (i%40)*10_000,
+				ExternalIDs: spotify.ExternalIDs{ISRC: fmt.Sprintf("USRC1%07d", i)},
+			},
+		}
+	}
+	return liked
+}
+
+func libraryTracks(liked []spotify.SavedTrack) []Track {
+	tracks := make([]Track, len(liked))
+	for i, song := range liked {
+		tracks[i] = song.Track
+	}
+	return tracks
+}
+
+// Transport answering the liked songs and playlist items endpoints from memory, each page
+// encoded once so the benchmarks measure the client side
+type libraryTransport struct {
+	liked    []spotify.SavedTrack
+	playlist []spotify.PlaylistTrack
+	pages    sync.Map
+}
+
+func (t *libraryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	body, ok := t.pages.Load(key)
+	if !ok {
+		data, err := t.page(req)
+		if err != nil {
+			return nil, err
+		}
+		body, _ = t.pages.LoadOrStore(key, data)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body.([]byte))),
+		Request:    req,
+	}, nil
+}
+
+func (t *libraryTransport) page(req *http.Request) ([]byte, error) {
+	query := req.URL.Query()
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/me/tracks"):
+		return json.Marshal(pageOf(t.liked, offset, limit))
+	case strings.HasSuffix(req.URL.Path, "/tracks"):
+		return json.Marshal(pageOf(t.playlist, offset, limit))
+	}
+	return nil, fmt.Errorf("unexpected request %s", req.URL)
+}
+
+func pageOf[T any](items []T, offset, limit int) spotify.Page[T] {
+	end := min(offset+limit, len(items))
+	page := spotify.Page[T]{Items: items[min(offset, end):end], Limit: limit, Offset: offset, Total: len(items)}
+	if end < len(items) {
+		page.Next = "next"
+	}
+	return page
+}
+
+// Function to call the Web API through the in-memory library for the rest of the benchmark
+func useLibrary(b *testing.B, transport *libraryTransport) {
+	previous := httpClient.Transport
+	httpClient.Transport = transport
+	b.Cleanup(func() { httpClient.Transport = previous })
+}
+
+// Function to drop the logs for the rest of the benchmark, the filters logging every skipped track
+func discardLogs(b *testing.B) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	b.Cleanup(func() { slog.SetDefault(previous) })
+}
+
+// Fetching the whole library page by page, merging the pages into one response
+func BenchmarkFetchLikedSongs(b *testing.B) {
+	discardLogs(b)
+	for _, n := range benchmarkLibrarySizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			transport := &libraryTransport{liked: generateLibrary(n)}
+			useLibrary(b, transport)
+			ctx := context.Background()
+			if _, err := fetchLikedSongs(ctx, "benchmark", time.Time{}); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				response, err := fetchLikedSongs(ctx, "benchmark", time.Time{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(response.Items) != n {
+					b.Fatalf("fetched %d liked songs, expected %d", len(response.Items), n)
+				}
+			}
+		})
+	}
+}
+
+// Keeping the songs of a month, then filtering them by explicitness, duration and artists
+func BenchmarkFilters(b *testing.B) {
+	filters := trackFilters{
+		SkipExplicit:    true,
+		MaxDuration:     5 * time.Minute,
+		ExcludedArtists: []string{"Artist 1", "Artist 42", "Artist 299"},
+	}
+	discardLogs(b)
+	for _, n := range benchmarkLibrarySizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			library := spotify.Page[LikedSong]{Items: generateLibrary(n), Total: n}
+			clock := fixedClock{t: time.Date(2025, 6, 30, 23, 0, 0, 0, time.UTC)}
+			tracks := libraryTracks(library.Items)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				filterLikedSongsForCurrentMonth(library, clock)
+				applyFilters(tracks, filters)
+			}
+		})
+	}
+}
+
+// Diffing the library against a playlist holding every other song
+func BenchmarkMissingFromPlaylist(b *testing.B) {
+	discardLogs(b)
+	for _, n := range benchmarkLibrarySizes {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			liked := generateLibrary(n)
+			transport := &libraryTransport{}
+			for i := 0; i < n; i += 2 {
+				transport.playlist = append(transport.playlist, spotify.PlaylistTrack{AddedAt: liked[i].AddedAt, Track: liked[i].Track})
+			}
+			useLibrary(b, transport)
+			tracks := libraryTracks(liked)
+			ctx := context.Background()
+			if _, err := missingFromPlaylist(ctx, "benchmark", "playlist", tracks); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				missing, err := missingFromPlaylist(ctx, "benchmark", "playlist", tracks)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(missing) != n/2 {
+					b.Fatalf("found %d missing tracks, expected %d", len(missing), n/2)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Most Spotify API calls a run may make, set with --max-api-calls; 0 for no limit. Retries of
+// a call don't count.
+var maxAPICalls int64
+
+// API calls made under one budget: each run has its own, the calls made outside of runs count
+// against the process's
+type apiBudget struct {
+	calls atomic.Int64
+}
+
+var processBudget = &apiBudget{}
+
+type budgetKey struct{}
+
+// Function to give the work done with the context a fresh API call budget
+func withAPIBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &apiBudget{})
+}
+
+func budgetFromContext(ctx context.Context) *apiBudget {
+	if budget, ok := ctx.Value(budgetKey{}).(*apiBudget); ok {
+		return budget
+	}
+	return processBudget
+}
+
+// Error of the calls made once the budget is exhausted
+var errAPIBudget = errors.New("the API call budget is exhausted")
+
+// Transport refusing the API calls beyond --max-api-calls
+type budgetTransport struct {
+	next http.RoundTripper
+}
+
+func (t budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if maxAPICalls > 0 && isAPIRequest(req.URL) {
+		if budgetFromContext(req.Context()).calls.Add(1) > maxAPICalls {
+			return nil, fmt.Errorf("%w (--max-api-calls %d)", errAPIBudget, maxAPICalls)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
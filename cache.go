@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/eduardohitek/spotify-cli/spotify"
+)
+
+// Track metadata doesn't change, the TTL only bounds how long removed tracks linger
+const defaultCacheTTL = 30 * 24 * time.Hour
+
+// Local cache of track metadata (name, artists, duration, ISRC) keyed by track ID, so tracks
+// already seen are never fetched again, and of artists with their genres keyed by artist ID
+type trackCache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	Entries map[string]cachedTrack  `json:"tracks"`
+	Artists map[string]cachedArtist `json:"artists,omitempty"`
+}
+
+type cachedTrack struct {
+	Track    Track     `json:"track"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+type cachedArtist struct {
+	Artist   Artist    `json:"artist"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// Function to get the default cache location, next to the state file
+func defaultCachePath() string {
+	return filepath.Join(filepath.Dir(defaultStatePath()), "cache.json")
+}
+
+// Function to load the cache, returning an empty one when the file doesn't exist yet.
+// An empty path gives a cache that is never saved.
+func loadTrackCache(path string, ttl time.Duration) (*trackCache, error) {
+	cache := &trackCache{path: path, ttl: ttl, Entries: map[string]cachedTrack{}, Artists: map[string]cachedArtist{}}
+	if path == "" {
+		return cache, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+	if cache.Entries == nil {
+		cache.Entries = map[string]cachedTrack{}
+	}
+	if cache.Artists == nil {
+		cache.Artists = map[string]cachedArtist{}
+	}
+	return cache, nil
+}
+
+func (c *trackCache) save() error {
+	if c == nil || c.path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	data, err := json.Marshal(c)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data, 0o600)
+}
+
+func (c *trackCache) get(id string) (Track, bool) {
+	if c == nil {
+		return Track{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.Entries[id]
+	if !ok || time.Since(entry.CachedAt) > c.ttl {
+		return Track{}, false
+	}
+	return entry.Track, true
+}
+
+// Function to remember the metadata of the given tracks
+func (c *trackCache) put(tracks []Track) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, track := range tracks {
+		if track.ID != "" && track.Name != "" {
+			c.Entries[track.ID] = cachedTrack{Track: track, CachedAt: now}
+		}
+	}
+}
+
+func (c *trackCache) hasArtist(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.Artists[id]
+	return ok && time.Since(entry.CachedAt) <= c.ttl
+}
+
+func (c *trackCache) putArtists(artists []Artist) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, artist := range artists {
+		c.Artists[artist.ID] = cachedArtist{Artist: artist, CachedAt: now}
+	}
+}
+
+// Function to drop the expired entries, returning how many were dropped
+func (c *trackCache) expire() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dropped := 0
+	for id, entry := range c.Entries {
+		if time.Since(entry.CachedAt) > c.ttl {
+			delete(c.Entries, id)
+			dropped++
+		}
+	}
+	for id, entry := range c.Artists {
+		if time.Since(entry.CachedAt) > c.ttl {
+			delete(c.Artists, id)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Function to fill in the metadata of tracks known only by their ID, like some source plugins
+// provide, from the cache and then from the API
+func enrichTracks(ctx context.Context, accessToken string, cache *trackCache, tracks []Track) ([]Track, error) {
+	var unknown []string
+	for i, track := range tracks {
+		if track.Name != "" {
+			continue
+		}
+		if cached, ok := cache.get(track.ID); ok {
+			tracks[i] = cached
+			continue
+		}
+		unknown = append(unknown, track.ID)
+	}
+
+	// The batches are looked up by up to --enrich-workers requests at once
+	var mu sync.Mutex
+	fetched := map[string]Track{}
+	batches := (len(unknown) + spotify.MaxTracksPerLookup - 1) / spotify.MaxTracksPerLookup
+	err := runConcurrently(enrichWorkers, batches, func(i int) error {
+		start := i * spotify.MaxTracksPerLookup
+		batch := unknown[start:min(start+spotify.MaxTracksPerLookup, len(unknown))]
+		found, err := apiClient(accessToken).Tracks(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("getting track metadata: %w", err)
+		}
+		cache.put(found)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, track := range found {
+			fetched[track.ID] = track
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, track := range tracks {
+		if found, ok := fetched[track.ID]; ok && track.Name == "" {
+			tracks[i] = found
+		}
+	}
+	return tracks, nil
+}
+
+// Function to manage the local track cache
+func runCache(ctx context.Context, args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: spotify-cli cache purge|warm [flags]")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("cache "+args[0], flag.ExitOnError)
+	cachePath := flags.String("cache-file", defaultCachePath(), "local cache of track metadata")
+	ttl := flags.Duration("cache-ttl", defaultCacheTTL, "how long cached track metadata is used")
+
+	switch args[0] {
+	case "purge":
+		expiredOnly := flags.Bool("expired", false, "only drop the entries older than --cache-ttl")
+		flags.Parse(args[1:])
+		if !*expiredOnly {
+			if err := os.Remove(*cachePath); err != nil && !os.IsNotExist(err) {
+				fmt.Println("Error removing cache:", err)
+				return
+			}
+			fmt.Println("Removed the cache", *cachePath)
+			return
+		}
+		cache, err := loadTrackCache(*cachePath, *ttl)
+		if err != nil {
+			fmt.Println("Error loading cache:", err)
+			return
+		}
+		dropped := cache.expire()
+		if err := cache.save(); err != nil {
+			fmt.Println("Error saving cache:", err)
+			return
+		}
+		fmt.Printf("Dropped %d expired entries from the cache.\n", dropped)
+	case "warm":
+		rate := flags.Float64("rate", 5, "most API requests per second")
+		sqlite := flags.Bool("sqlite", false, "also write the library into the SQLite analytics cache read by the query command")
+		sqlitePath := flags.String("sqlite-file", defaultAnalyticsPath(), "SQLite analytics cache written by --sqlite")
+		flags.Parse(args[1:])
+		if !*sqlite {
+			*sqlitePath = ""
+		}
+		cache, err := loadTrackCache(*cachePath, *ttl)
+		if err != nil {
+			fmt.Println("Error loading cache:", err)
+			return
+		}
+		if err := warmCache(ctx, cache, *rate, *sqlitePath); err != nil {
+			fmt.Println("Error warming cache:", err)
+			return
+		}
+		if err := cache.save(); err != nil {
+			fmt.Println("Error saving cache:", err)
+		}
+	default:
+		fmt.Printf("Unknown cache command %q; available: purge, warm\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// Function to fill the cache with the whole liked library and the artists behind it, sending
+// at most rate requests per second, then the SQLite analytics cache at sqlitePath unless it's empty
+func warmCache(ctx context.Context, cache *trackCache, rate float64, sqlitePath string) error {
+	if rate <= 0 {
+		return fmt.Errorf("--rate must be positive")
+	}
+	baseTransport = &throttledTransport{next: baseTransport, interval: time.Duration(float64(time.Second) / rate)}
+	httpClient.Transport = baseTransport
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+
+	liked, err := fetchLikedSongs(ctx, token.AccessToken, time.Time{})
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	var artistIDs []string
+	seen := map[string]bool{}
+	for _, song := range liked.Items {
+		cache.put([]Track{song.Track})
+		for _, artist := range song.Track.Artists {
+			if !seen[artist.ID] && !cache.hasArtist(artist.ID) {
+				seen[artist.ID] = true
+				artistIDs = append(artistIDs, artist.ID)
+			}
+		}
+	}
+	slog.InfoContext(ctx, "Cached the liked tracks; fetching their artists", "tracks", len(liked.Items), "artists", len(artistIDs))
+
+	batches := (len(artistIDs) + spotify.MaxArtistsPerLookup - 1) / spotify.MaxArtistsPerLookup
+	err = runConcurrently(enrichWorkers, batches, func(i int) error {
+		start := i * spotify.MaxArtistsPerLookup
+		batch := artistIDs[start:min(start+spotify.MaxArtistsPerLookup, len(artistIDs))]
+		artists, err := apiClient(token.AccessToken).Artists(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("getting artists: %w", err)
+		}
+		cache.putArtists(artists)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("The cache holds %d track(s) and %d artist(s).\n", len(cache.Entries), len(cache.Artists))
+	if sqlitePath == "" {
+		return nil
+	}
+	if err := writeAnalytics(ctx, sqlitePath, liked.Items, cache); err != nil {
+		return fmt.Errorf("writing the analytics cache: %w", err)
+	}
+	fmt.Println("Wrote the analytics cache", sqlitePath)
+	return nil
+}
+
+// Transport spacing requests at least interval apart
+type throttledTransport struct {
+	mu       sync.Mutex
+	next     http.RoundTripper
+	interval time.Duration
+	last     time.Time
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if wait := t.interval - time.Since(t.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	t.last = time.Now()
+	t.mu.Unlock()
+	return t.next.RoundTrip(req)
+}
+
+// Function to save the cache at the end of a run, logging failures since the cache is only an optimization
+func saveTrackCache(cache *trackCache) {
+	if err := cache.save(); err != nil {
+		slog.Warn("Could not save the track cache", "error", err)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A subcommand of the tool
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string)
+}
+
+var commands = []command{
+	{"sync", "sync this month's liked songs into the monthly playlist (the default)", runSync},
+	{"backfill", "create and fill the monthly playlists of past months", runBackfill},
+	{"resync", "sync a past month's playlist again", runResync},
+	{"rebuild", "empty a managed month's playlist of your tracks, keeping the collaborators', and fill it again in order", runRebuild},
+	{"adopt", "take over the monthly playlists created before the tool kept state", runAdopt},
+	{"like", "add tracks to the liked songs", runLike},
+	{"unlike", "remove tracks from the liked songs", runUnlike},
+	{"playlist", "change the name, description or visibility of a playlist (edit)", runPlaylist},
+	{"wrapped", "build the year-in-review playlist, e.g. Best of 2025", runWrapped},
+	{"audit-privacy", "list the public and collaborative flags of the managed playlists, fixing them to the policy with --fix", runAuditPrivacy},
+	{"unfollow", "unfollow (delete) managed playlists and drop them from the state", runUnfollow},
+	{"auth", "authorize the Spotify app and save its refresh token (login)", runAuth},
+	{"whoami", "show which account the credentials are bound to", runWhoami},
+	{"status", "show the authenticated account, its token and the local state", runStatus},
+	{"export", "export the liked library", runExport},
+	{"query", "run a predefined or ad-hoc SQL query over the SQLite analytics cache", runQuery},
+	{"cache", "manage the local track metadata cache (purge, warm)", runCache},
+	{"config", "check the config file and credentials or print the effective settings (validate, show)", runConfig},
+	{"state", "inspect, verify and repair the local state (show, verify, repair, gc)", runState},
+	{"embed", "print an embed snippet for a monthly playlist", runEmbed},
+	{"watch", "poll the liked songs and add the new ones within minutes", runWatch},
+	{"tenant", "serve several users from one process (add, list, pause, resume, remove, login, serve)", runTenant},
+	{"daemon", "keep syncing on a schedule, every interval or at cron times", runDaemon},
+	{"ctl", "command a running daemon through its control API (status, sync, reload, pause, resume)", runCtl},
+	{"serve", "serve the control API: syncs triggered with POST /sync, the status and pausing, with role keys", runServe},
+	{"slack", "serve Slack slash commands", runSlack},
+	{"matrix-bot", "answer !sync in a Matrix room", runMatrixBot},
+	{"grafana", "serve the run history to Grafana's JSON datasource", runGrafana},
+	{"e2e", "run the end-to-end test against a dedicated account", runE2E},
+}
+
+// Flags given before the subcommand, applying to all of them
+type globalFlags struct {
+	config     *string
+	configFile *string
+	nameFormat *string
+	nameTmpl   *string
+	nameLocale *string
+	public     *bool
+	userID     *string
+	timezone   *string
+	period     *string
+	pageWork   *int
+	enrichWork *int
+	apiCalls   *int64
+	verbose    *bool
+	quiet      *bool
+	logLevel   *string
+	logFormat  *string
+	dryRun     *bool
+	backups    *int
+	retries    *int
+	timeout    *time.Duration
+	// Access token cache and how long before their expiry cached tokens are refreshed
+	tokenCache   *string
+	tokenRefresh *time.Duration
+}
+
+// Set by --dry-run: syncs only read from Spotify and print what they would change
+var dryRun bool
+
+func registerGlobalFlags(flags *flag.FlagSet) *globalFlags {
+	g := &globalFlags{}
+	g.config = flags.String("config", envFile, "env file holding the Spotify credentials and settings")
+	g.configFile = flags.String("config-file", configFile, "YAML file with the settings: global flags at the top level, sync flags in a sync section")
+	g.nameFormat = flags.String("name-format", playlistNameFormat, "Go time layout of the monthly playlist names, e.g. \"January 2006\"")
+	g.nameTmpl = flags.String("name-template", "", "Go template of the playlist titles in Spotify, e.g. \"{{.MonthName}} {{.Year}} – Likes\", with .Month, .MonthName, .ShortMonthName, .Year, .ShortYear, .Week, .Quarter and .Tracks")
+	g.nameLocale = flags.String("name-locale", playlistNameLocale, "language of the month names in --name-template: en, pt, es, fr or de")
+	g.period = flags.String("period", syncPeriod, "granularity of the playlists: weekly, monthly, quarterly or yearly")
+	g.timezone = flags.String("timezone", "Local", "IANA time zone the months are counted in, e.g. America/Sao_Paulo")
+	g.public = flags.Bool("public", false, "create the playlists as public instead of private")
+	g.userID = flags.String("user-id", "", "create the playlists for this user instead of the authenticated one")
+	g.verbose = flags.Bool("verbose", false, "log every Spotify API request, as --log-level debug")
+	flags.BoolVar(g.verbose, "v", false, "shorthand for --verbose")
+	g.quiet = flags.Bool("quiet", false, "only print errors and results, no progress logs, as --log-level error")
+	flags.BoolVar(g.quiet, "q", false, "shorthand for --quiet")
+	g.logLevel = flags.String("log-level", "info", "least level of the logs: debug, info, warn or error")
+	g.logFormat = flags.String("log-format", "text", "format of the logs, written to stderr: text or json")
+	g.dryRun = flags.Bool("dry-run", false, "read from Spotify but only print the playlists and tracks a sync would create and add")
+	g.retries = flags.Int("max-attempts", maxAttempts, "most attempts at each request; rate limits and server errors are retried with backoff")
+	g.timeout = flags.Duration("timeout", requestTimeout, "how long each request to Spotify and the notifiers may take")
+	g.tokenCache = flags.String("token-cache", tokenCachePath, "file caching the access tokens between runs (empty to disable)")
+	g.tokenRefresh = flags.Duration("token-refresh-margin", tokenRefreshMargin, "how long before expiring a cached access token is refreshed")
+	g.pageWork = flags.Int("page-workers", pageWorkers, "most pages of a playlist fetched at once")
+	g.enrichWork = flags.Int("enrich-workers", enrichWorkers, "most track and artist lookups sent at once")
+	g.apiCalls = flags.Int64("max-api-calls", 0, "stop the run once it made this many Spotify API calls, keeping its progress (0 for no limit)")
+	g.backups = flags.Int("backups", backupCount, "rotated backups kept of the state and env files (0 keeps none)")
+	return g
+}
+
+// Function to run the subcommand named in the arguments, after the global flags.
+// A leading flag that isn't global starts the sync flags, as before there were subcommands.
+func runCLI(args []string) {
+	flags := flag.NewFlagSet("spotify-cli", flag.ExitOnError)
+	flags.Usage = func() { printUsage(flags) }
+	g := registerGlobalFlags(flags)
+	n := leadingGlobalFlags(flags, args)
+	flags.Parse(args[:n])
+	args = args[n:]
+
+	envFile = *g.config
+	configFile = *g.configFile
+	loadEnvFile()
+	var err error
+	if settings, err = loadConfig(configFile); err != nil {
+		fmt.Println("Error loading config file:", err)
+		os.Exit(2)
+	}
+	globalFlagSet, globalCLIFlags = flags, explicitFlags(flags)
+	unknown, errs := applySettings(flags, globalSettings(), globalCLIFlags, nil)
+	if len(errs) > 0 {
+		fmt.Println("Error applying config file:", errs[0])
+		os.Exit(2)
+	}
+	if err := setupLogging(*g.logLevel, *g.logFormat, *g.verbose, *g.quiet); err != nil {
+		fmt.Println("Error", err)
+		os.Exit(2)
+	}
+	for _, key := range unknown {
+		slog.Warn("Ignoring an unknown setting of the config file", "setting", key, "path", configFile)
+	}
+
+	if monthZone, err = time.LoadLocation(*g.timezone); err != nil {
+		fmt.Println("Error parsing --timezone:", err)
+		os.Exit(2)
+	}
+	if syncPeriod, err = parsePeriod(*g.period); err != nil {
+		fmt.Println("Error parsing --period:", err)
+		os.Exit(2)
+	}
+	playlistNameFormat = *g.nameFormat
+	playlistNameLocale = *g.nameLocale
+	if playlistNameTemplate, err = parseNameTemplate(*g.nameTmpl, playlistNameLocale); err != nil {
+		fmt.Println("Error parsing --name-template:", err)
+		os.Exit(2)
+	}
+	playlistsPublic = *g.public
+	userIDOverride = *g.userID
+	dryRun = *g.dryRun
+	backupCount = *g.backups
+	maxAttempts = max(*g.retries, 1)
+	requestTimeout = *g.timeout
+	maxAPICalls = *g.apiCalls
+	pageWorkers = max(*g.pageWork, 1)
+	enrichWorkers = max(*g.enrichWork, 1)
+	tokenCachePath = *g.tokenCache
+	tokenRefreshMargin = *g.tokenRefresh
+	setupVCR()
+	if logLevel.Level() <= slog.LevelDebug {
+		baseTransport = verboseTransport{next: baseTransport}
+	}
+	baseTransport = correlationTransport{next: budgetTransport{next: retryTransport{next: metricsTransport{next: baseTransport}}}}
+	httpClient.Transport = baseTransport
+
+	name := "sync"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage(flags)
+		return
+	}
+	for _, c := range commands {
+		if c.name == name {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go func() {
+				// A second interrupt kills the process as usual
+				<-ctx.Done()
+				stop()
+			}()
+			c.run(ctx, args)
+			return
+		}
+	}
+	fmt.Printf("Unknown command %q\n", name)
+	printUsage(flags)
+	os.Exit(2)
+}
+
+// Function to count the arguments taken by the global flags at the start of args
+func leadingGlobalFlags(flags *flag.FlagSet, args []string) int {
+	i := 0
+	for i < len(args) {
+		name, hasValue := strings.CutPrefix(args[i], "-")
+		if !hasValue || name == "" || name == "-" {
+			break
+		}
+		name = strings.TrimPrefix(name, "-")
+		name, _, hasValue = strings.Cut(name, "=")
+		f := flags.Lookup(name)
+		if f == nil {
+			break
+		}
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		if hasValue || (ok && boolFlag.IsBoolFlag()) {
+			i++
+		} else {
+			i += 2
+		}
+	}
+	return min(i, len(args))
+}
+
+func printUsage(flags *flag.FlagSet) {
+	out := flags.Output()
+	fmt.Fprintln(out, "Usage: spotify-cli [global flags] <command> [flags]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(out, "\nGlobal flags:")
+	flags.PrintDefaults()
+	fmt.Fprintln(out, "\nRun spotify-cli <command> -h for the flags of a command.")
+}
+
+// Transport logging the method, URL, status and duration of every request at the debug level
+type verboseTransport struct {
+	next http.RoundTripper
+}
+
+func (t verboseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		slog.DebugContext(req.Context(), "Spotify request failed", "method", req.Method, "url", req.URL.Redacted(), "error", err, "duration", time.Since(start))
+		return nil, err
+	}
+	slog.DebugContext(req.Context(), "Spotify request", "method", req.Method, "url", req.URL.Redacted(), "status", resp.StatusCode, "duration", time.Since(start))
+	return resp, nil
+}
+
+// Function to serve HTTP until the context is cancelled, then shut down gracefully
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		slog.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Function to serve HTTP in the background until the context is cancelled, returning the
+// function waiting for the server to shut down
+func serveInBackground(ctx context.Context, what, addr string, handler http.Handler) func() {
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		slog.Info("Serving the "+what, "addr", addr)
+		if err := serve(ctx, addr, handler); err != nil {
+			slog.Error("The "+what+" stopped", "error", err)
+		}
+	}()
+	return func() { <-served }
+}
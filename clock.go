@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Source of the current time for every date-based decision (month filtering, playlist naming),
+// so runs can be simulated as of another date
+type Clock interface {
+	Now() time.Time
+}
+
+// Time zone the months are counted in, set with --timezone
+var monthZone = time.Local
+
+// Function to get the current time in the zone the months are counted in
+func nowInMonthZone() time.Time {
+	return time.Now().In(monthZone)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return nowInMonthZone()
+}
+
+// A clock frozen at a given instant
+type fixedClock struct {
+	t time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.t
+}
+
+// Function to build the clock for the --as-of flag: the system clock when empty,
+// otherwise a clock frozen at the end of the given day in the --timezone zone
+func parseAsOf(value string) (Clock, error) {
+	if value == "" {
+		return systemClock{}, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, monthZone)
+	if err != nil {
+		return nil, fmt.Errorf("expected a date like 2025-01-31: %w", err)
+	}
+	asOf := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	slog.Info("Running as of another time", "as_of", asOf.Format(time.RFC3339))
+	return fixedClock{t: asOf}, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// Zone with DST, whose months start an hour or two before UTC's: at 22:00 or 23:00 UTC
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	zone, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zone
+}
+
+// Function to use the period for the rest of the test
+func usePeriod(t *testing.T, period string) {
+	previous := syncPeriod
+	syncPeriod = period
+	t.Cleanup(func() { syncPeriod = previous })
+}
+
+func TestPeriodStart(t *testing.T) {
+	zone := berlin(t)
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, zone)
+	}
+	tests := []struct {
+		name   string
+		period string
+		t      time.Time
+		want   time.Time
+	}{
+		{"end of a month", periodMonthly, at(2025, 3, 31, 23, 30), at(2025, 3, 1, 0, 0)},
+		{"start of a month, still the previous one in UTC", periodMonthly, at(2025, 4, 1, 0, 15), at(2025, 4, 1, 0, 0)},
+		{"end of a year", periodMonthly, at(2024, 12, 31, 23, 59), at(2024, 12, 1, 0, 0)},
+		{"start of a year, still the previous one in UTC", periodMonthly, at(2025, 1, 1, 0, 0), at(2025, 1, 1, 0, 0)},
+		{"day DST starts", periodMonthly, at(2025, 3, 30, 3, 30), at(2025, 3, 1, 0, 0)},
+		{"day DST ends, in the repeated hour", periodMonthly, time.Date(2025, 10, 26, 0, 30, 0, 0, time.UTC).In(zone), at(2025, 10, 1, 0, 0)},
+		{"week across the start of DST", periodWeekly, at(2025, 3, 30, 12, 0), at(2025, 3, 24, 0, 0)},
+		{"Monday after the start of DST", periodWeekly, at(2025, 3, 31, 0, 30), at(2025, 3, 31, 0, 0)},
+		{"week across a year", periodWeekly, at(2025, 1, 1, 9, 0), at(2024, 12, 30, 0, 0)},
+		{"quarter", periodQuarterly, at(2025, 6, 30, 23, 0), at(2025, 4, 1, 0, 0)},
+		{"year", periodYearly, at(2025, 1, 1, 0, 5), at(2025, 1, 1, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePeriod(t, tt.period)
+			if got := periodStart(tt.t); !got.Equal(tt.want) {
+				t.Errorf("periodStart(%s) = %s, want %s", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissedMonths(t *testing.T) {
+	zone := berlin(t)
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	endOf := func(year int, month time.Month) time.Time {
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, zone).Add(-time.Nanosecond)
+	}
+	tests := []struct {
+		name         string
+		lastSyncedAt time.Time
+		now          time.Time
+		want         []time.Time
+	}{
+		{"never synced", time.Time{}, utc(2025, 4, 15, 12, 0), nil},
+		{"synced this month", utc(2025, 4, 2, 8, 0), utc(2025, 4, 15, 12, 0), nil},
+		{"last synced before the month ended", utc(2025, 3, 31, 21, 0), utc(2025, 4, 15, 12, 0), []time.Time{endOf(2025, 3)}},
+		{"last synced after the month ended here, not in UTC", utc(2025, 3, 31, 22, 30), utc(2025, 4, 15, 12, 0), nil},
+		{"across a year", utc(2024, 11, 20, 12, 0), utc(2025, 1, 5, 12, 0), []time.Time{endOf(2024, 11), endOf(2024, 12)}},
+		{"month DST ends in", utc(2025, 10, 26, 1, 30), utc(2025, 11, 1, 0, 30), []time.Time{endOf(2025, 10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePeriod(t, periodMonthly)
+			state := syncState{LastSyncedAt: tt.lastSyncedAt}
+			got := state.missedMonths(tt.now.In(zone))
+			if len(got) != len(tt.want) {
+				t.Fatalf("missedMonths = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("missedMonths[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterLikedSongsForCurrentMonth(t *testing.T) {
+	zone := berlin(t)
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	liked := func(id string, at time.Time) LikedSong {
+		return LikedSong{AddedAt: at, Track: Track{ID: id}}
+	}
+	tests := []struct {
+		name  string
+		now   time.Time
+		songs []LikedSong
+		want  []string
+	}{
+		{
+			"start of a month, still the previous one in UTC",
+			utc(2025, 3, 31, 22, 30),
+			[]LikedSong{
+				liked("later", utc(2025, 3, 31, 22, 45)),
+				liked("this month", utc(2025, 3, 31, 22, 15)),
+				liked("last month", utc(2025, 3, 31, 21, 59)),
+			},
+			[]string{"this month"},
+		},
+		{
+			"start of a year",
+			utc(2024, 12, 31, 23, 10),
+			[]LikedSong{
+				liked("this year", utc(2024, 12, 31, 23, 5)),
+				liked("last year", utc(2024, 12, 31, 22, 55)),
+			},
+			[]string{"this year"},
+		},
+		{
+			"day DST ends",
+			utc(2025, 10, 26, 2, 30),
+			[]LikedSong{
+				liked("repeated hour", utc(2025, 10, 26, 0, 30)),
+				liked("first of the month", utc(2025, 9, 30, 22, 30)),
+				liked("last month", utc(2025, 9, 30, 21, 30)),
+			},
+			[]string{"repeated hour", "first of the month"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePeriod(t, periodMonthly)
+			response := LikedSongsSearchResponse{Items: tt.songs, Total: len(tt.songs)}
+			var got []string
+			for _, track := range filterLikedSongsForCurrentMonth(response, fixedClock{t: tt.now.In(zone)}) {
+				got = append(got, track.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config file holding the settings, set with --config-file. Its top-level keys are the global
+// flags and its sync section the flags of every command that syncs, e.g.
+//
+//	name-format: "January 2006"
+//	public: true
+//	sync:
+//	  description: Liked this month
+//	  position: prepend
+//	daemon:
+//	  every: 6h
+//
+// SPOTIFY_<FLAG> environment variables (e.g. SPOTIFY_POSITION) override the file and flags
+// given on the command line override both.
+var configFile = filepath.Join(filepath.Dir(defaultStatePath()), "config.yaml")
+
+// Settings loaded from the config file
+var settings map[string]any
+
+// Flags locating the files, which can't come from the config file
+var fileFlags = map[string]bool{"config": true, "config-file": true}
+
+// Function to load the config file, returning no settings when it doesn't exist
+func loadConfig(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Function to get the sync section of the settings
+func syncSettings() map[string]any {
+	section, _ := settings["sync"].(map[string]any)
+	return section
+}
+
+// Function to set the flags not given on the command line from the settings and then the
+// environment, limited to the named flags when names isn't nil. It returns the settings that
+// don't match a flag and the ones that don't parse.
+func applySettings(flags *flag.FlagSet, values map[string]any, explicit, names map[string]bool) (unknown []string, errs []error) {
+	settable := func(name string) bool {
+		return flags.Lookup(name) != nil && !fileFlags[name] && (names == nil || names[name])
+	}
+	for key, value := range values {
+		if !settable(key) {
+			unknown = append(unknown, key)
+			continue
+		}
+		if explicit[key] {
+			continue
+		}
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+		for _, item := range items {
+			if err := flags.Set(key, fmt.Sprint(item)); err != nil {
+				errs = append(errs, fmt.Errorf("setting %s: %w", key, err))
+			}
+		}
+	}
+	flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(settingEnv(f.Name))
+		if !ok || explicit[f.Name] || !settable(f.Name) {
+			return
+		}
+		if err := flags.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("setting %s from %s: %w", f.Name, settingEnv(f.Name), err))
+		}
+	})
+	sort.Strings(unknown)
+	return unknown, errs
+}
+
+// Function to list the flags given on the command line
+func explicitFlags(flags *flag.FlagSet) map[string]bool {
+	explicit := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	return explicit
+}
+
+// Function to get the global settings: the config file without its sync and daemon sections
+func globalSettings() map[string]any {
+	values := map[string]any{}
+	for key, value := range settings {
+		if key != "sync" && key != "daemon" {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// Function to get the environment variable overriding a flag, e.g. SPOTIFY_DRY_RUN
+func settingEnv(name string) string {
+	return "SPOTIFY_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Function to check the config file and the credentials, or print the effective settings
+func runConfig(ctx context.Context, args []string) {
+	if len(args) == 0 || (args[0] != "validate" && args[0] != "show") {
+		fmt.Println("Usage: spotify-cli config validate|show [flags]")
+		os.Exit(2)
+	}
+	if args[0] == "show" {
+		runConfigShow(args[1:])
+		return
+	}
+
+	problems := validateConfig()
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Found %d problem(s) in the configuration.\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Println("The configuration is valid.")
+}
+
+// Function to list what's wrong with the config file and the credentials
+func validateConfig() []string {
+	var problems []string
+	globalSet := flag.NewFlagSet("spotify-cli", flag.ContinueOnError)
+	registerGlobalFlags(globalSet)
+	unknown, errs := applySettings(globalSet, globalSettings(), nil, nil)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("Unknown setting %q in %s.", key, configFile))
+	}
+	for _, err := range errs {
+		problems = append(problems, "Invalid setting: "+err.Error())
+	}
+
+	if _, ok := settings["sync"].(map[string]any); settings["sync"] != nil && !ok {
+		problems = append(problems, fmt.Sprintf("The sync section of %s must be a mapping.", configFile))
+	}
+	syncSet := flag.NewFlagSet("sync", flag.ContinueOnError)
+	sf := registerSyncFlags(syncSet)
+	unknown, errs = applySettings(syncSet, syncSettings(), nil, sf.names)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("Unknown setting %q in the sync section of %s.", key, configFile))
+	}
+	for _, err := range errs {
+		problems = append(problems, "Invalid sync setting: "+err.Error())
+	}
+
+	if _, ok := settings["daemon"].(map[string]any); settings["daemon"] != nil && !ok {
+		problems = append(problems, fmt.Sprintf("The daemon section of %s must be a mapping.", configFile))
+	}
+	daemonSet := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	df := registerDaemonFlags(daemonSet)
+	unknown, errs = applySettings(daemonSet, daemonSettings(), nil, daemonFlagNames)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("Unknown setting %q in the daemon section of %s.", key, configFile))
+	}
+	for _, err := range errs {
+		problems = append(problems, "Invalid daemon setting: "+err.Error())
+	}
+	if _, err := parseSchedule(*df.cron, *df.every, systemClock{}); err != nil {
+		problems = append(problems, "Invalid daemon setting: "+err.Error())
+	}
+
+	for _, app := range loadApps() {
+		if app.ClientID == "" {
+			problems = append(problems, fmt.Sprintf("The client ID of the %s app is missing from %s.", app.Name, envFile))
+		}
+		if app.RefreshToken == "" {
+			problems = append(problems, fmt.Sprintf("The refresh token of the %s app is missing; run auth login.", app.Name))
+		}
+	}
+	return problems
+}
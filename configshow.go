@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// Global flags as resolved by runCLI, and the ones given on the command line
+var (
+	globalFlagSet  *flag.FlagSet
+	globalCLIFlags map[string]bool
+)
+
+// Environment variables holding the credentials and the notifier settings, read from the env file
+var credentialEnvs = []string{
+	"SPOTIFY_CLIENT_ID", "SPOTIFY_CLIENT_SECRET", "SPOTIFY_REFRESH_TOKEN",
+	"SPOTIFY_SECONDARY_CLIENT_ID", "SPOTIFY_SECONDARY_CLIENT_SECRET", "SPOTIFY_SECONDARY_REFRESH_TOKEN",
+	"SPOTIFY_NOTIFY_WEBHOOK_URL", "SPOTIFY_NOTIFY_NTFY_SERVER", "SPOTIFY_NOTIFY_NTFY_TOPIC", "SPOTIFY_NOTIFY_NTFY_TOKEN",
+	"SPOTIFY_NOTIFY_GOTIFY_URL", "SPOTIFY_NOTIFY_GOTIFY_TOKEN", "SPOTIFY_NOTIFY_PUSHOVER_TOKEN", "SPOTIFY_NOTIFY_PUSHOVER_USER",
+	"SPOTIFY_NOTIFY_MATRIX_HOMESERVER", "SPOTIFY_NOTIFY_MATRIX_ROOM", "SPOTIFY_NOTIFY_MATRIX_TOKEN",
+	"SLACK_SIGNING_SECRET", "SPOTIFY_TRIGGER_SECRET", controlKeysEnv, tenantKeyEnv, tenantAdminPasswordEnv, "SPOTIFY_VCR_MODE", "SPOTIFY_VCR_CASSETTE",
+}
+
+// Environment variables whose values are masked; webhook URLs embed their secret
+var secretEnv = regexp.MustCompile(`SECRET|TOKEN|KEY|PASSWORD|WEBHOOK_URL`)
+
+// A setting as in effect and where its value comes from: flag, env, file or default
+type resolvedSetting struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Source  string `json:"source"`
+}
+
+// Function to print the settings in effect after the config file, the environment and the flags
+func runConfigShow(args []string) {
+	flags := flag.NewFlagSet("config show", flag.ExitOnError)
+	resolved := flags.Bool("resolved", false, "also list the settings left at their defaults")
+	format := flags.String("format", "table", "output format: "+formatNames())
+	tmpl := flags.String("template", "", "Go template used by --format template")
+	flags.Parse(args)
+
+	var shown []resolvedSetting
+	for _, setting := range resolvedSettings() {
+		if *resolved || setting.Source != "default" {
+			shown = append(shown, setting)
+		}
+	}
+	if err := writeReport(os.Stdout, *format, *tmpl, configReport(shown)); err != nil {
+		fmt.Println("Error writing the configuration:", err)
+	}
+}
+
+// Function to resolve every setting: the global flags as parsed, the sync and daemon flags from
+// their config sections and the environment, and the credentials, the secret ones masked
+func resolvedSettings() []resolvedSetting {
+	var resolved []resolvedSetting
+	if globalFlagSet != nil {
+		resolved = append(resolved, flagSettings("global", globalFlagSet, globalSettings(), globalCLIFlags)...)
+	}
+
+	syncSet := flag.NewFlagSet("sync", flag.ContinueOnError)
+	sf := registerSyncFlags(syncSet)
+	applySettings(syncSet, syncSettings(), nil, sf.names)
+	resolved = append(resolved, flagSettings("sync", syncSet, syncSettings(), nil)...)
+
+	daemonSet := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	registerDaemonFlags(daemonSet)
+	applySettings(daemonSet, daemonSettings(), nil, daemonFlagNames)
+	resolved = append(resolved, flagSettings("daemon", daemonSet, daemonSettings(), nil)...)
+
+	for _, name := range credentialEnvs {
+		value, ok := os.LookupEnv(name)
+		source := "env"
+		if !ok {
+			source = "default"
+		}
+		if secretEnv.MatchString(name) {
+			value = maskSecret(value)
+		}
+		resolved = append(resolved, resolvedSetting{Section: "credentials", Name: name, Value: value, Source: source})
+	}
+	return resolved
+}
+
+// Function to list the flags of a set with their values and sources
+func flagSettings(section string, flags *flag.FlagSet, values map[string]any, cli map[string]bool) []resolvedSetting {
+	var resolved []resolvedSetting
+	flags.VisitAll(func(f *flag.Flag) {
+		// The shorthands mirror their long flags
+		if len(f.Name) == 1 {
+			return
+		}
+		source := "default"
+		_, inFile := values[f.Name]
+		_, inEnv := os.LookupEnv(settingEnv(f.Name))
+		switch {
+		case givenOnCommandLine(flags, f, cli):
+			source = "flag"
+		case fileFlags[f.Name]:
+		case inEnv:
+			source = "env " + settingEnv(f.Name)
+		case inFile:
+			source = "file"
+		}
+		resolved = append(resolved, resolvedSetting{Section: section, Name: f.Name, Value: f.Value.String(), Source: source})
+	})
+	sort.SliceStable(resolved, func(i, j int) bool { return resolved[i].Name < resolved[j].Name })
+	return resolved
+}
+
+// Function to tell whether a flag was given on the command line, itself or through its shorthand
+func givenOnCommandLine(flags *flag.FlagSet, f *flag.Flag, cli map[string]bool) bool {
+	if cli[f.Name] {
+		return true
+	}
+	short := flags.Lookup(f.Name[:1])
+	return short != nil && short != f && cli[short.Name] && short.Value == f.Value
+}
+
+// Function to mask a secret, keeping its last four characters when it's long enough for them
+// to give nothing away
+func maskSecret(value string) string {
+	switch {
+	case value == "":
+		return ""
+	case len(value) >= 16:
+		return "****" + value[len(value)-4:]
+	}
+	return "****"
+}
+
+func configReport(settings []resolvedSetting) report {
+	var rows [][]string
+	for _, s := range settings {
+		rows = append(rows, []string{s.Section, s.Name, s.Value, s.Source})
+	}
+	return report{Columns: []string{"SECTION", "SETTING", "VALUE", "SOURCE"}, Rows: rows, Data: settings}
+}
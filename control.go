@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variable holding the keys of the control API as comma-separated role:key pairs,
+// e.g. status:<key>,trigger:<key>,admin:<key>; each role may be given several keys
+const controlKeysEnv = "SPOTIFY_CONTROL_KEYS"
+
+// Header carrying the shared secret of the sync trigger, which the keys can also be sent in
+const triggerSecretHeader = "X-Sync-Secret"
+
+// Roles of the control API keys, each allowed what the ones before it are
+type controlRole int
+
+const (
+	// GET /status
+	roleStatus controlRole = iota + 1
+	// POST /sync
+	roleTrigger
+	// POST /pause, /resume and /reload
+	roleAdmin
+)
+
+var controlRoles = map[string]controlRole{"status": roleStatus, "trigger": roleTrigger, "admin": roleAdmin}
+
+// Shortest key accepted, so a dashboard can't be handed a guessable one
+const minControlKeyLength = 16
+
+type controlKey struct {
+	role controlRole
+	key  string
+}
+
+var (
+	errSyncBusy   = errors.New("a sync is already running")
+	errSyncPaused = errors.New("the syncs are paused")
+)
+
+// Server of the control API of serve and the daemon: status reads, triggered syncs and pausing,
+// each with keys of its own
+type controlServer struct {
+	keys []controlKey
+	// Runs one sync, which outlives the request triggering it
+	perform func() (runSummary, error)
+	// Reloads the settings of the daemon, nil for serve, which has none to reload
+	reload      func() error
+	historyPath string
+	// Only one sync runs at a time
+	busy sync.Mutex
+
+	mu      sync.Mutex
+	running bool
+	paused  bool
+	next    time.Time
+	last    *runSummary
+}
+
+// Outcome of a control request, with the summary of a triggered sync
+type controlResponse struct {
+	OK      bool        `json:"ok"`
+	Error   string      `json:"error,omitempty"`
+	Summary *runSummary `json:"summary,omitempty"`
+}
+
+// Answer of GET /status
+type controlStatus struct {
+	Running  bool        `json:"running"`
+	Paused   bool        `json:"paused"`
+	NextSync *time.Time  `json:"next_sync,omitempty"`
+	LastRun  *runSummary `json:"last_run,omitempty"`
+}
+
+// Function to read the keys of the control API, SPOTIFY_TRIGGER_SECRET being a trigger key
+func loadControlKeys() ([]controlKey, error) {
+	var keys []controlKey
+	for _, pair := range strings.Split(os.Getenv(controlKeysEnv), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, key, _ := strings.Cut(pair, ":")
+		role, ok := controlRoles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q in %s: use status, trigger or admin", name, controlKeysEnv)
+		}
+		if len(key) < minControlKeyLength {
+			return nil, fmt.Errorf("the %s keys of %s must be at least %d characters; generate them with openssl rand -hex 32", name, controlKeysEnv, minControlKeyLength)
+		}
+		keys = append(keys, controlKey{role: role, key: key})
+	}
+	if secret := os.Getenv("SPOTIFY_TRIGGER_SECRET"); secret != "" {
+		if len(secret) < minControlKeyLength {
+			return nil, fmt.Errorf("SPOTIFY_TRIGGER_SECRET must be at least %d characters; generate it with openssl rand -hex 32", minControlKeyLength)
+		}
+		keys = append(keys, controlKey{role: roleTrigger, key: secret})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s or SPOTIFY_TRIGGER_SECRET is required to authorize the control requests", controlKeysEnv)
+	}
+	return keys, nil
+}
+
+// Function to serve the control API, triggering syncs for external schedulers and webhooks
+func runServe(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	// Cloud Run and Fly tell the port to listen on in $PORT
+	defaultAddr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		defaultAddr = ":" + port
+	}
+	addr := flags.String("addr", defaultAddr, "address to listen on, :$PORT when PORT is set")
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	keys, err := loadControlKeys()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	// A dropped connection doesn't abort the sync, only the server shutting down does
+	server := &controlServer{
+		keys:        keys,
+		perform:     func() (runSummary, error) { return runSyncWithHooks(ctx, opts) },
+		historyPath: opts.HistoryPath,
+	}
+	slog.Info("Serving the control API", "addr", *addr)
+	if err := serve(ctx, *addr, server.handler()); err != nil {
+		fmt.Println("Error serving:", err)
+	}
+}
+
+func (s *controlServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /status", s.require(roleStatus, s.handleStatus))
+	mux.HandleFunc("POST /sync", s.require(roleTrigger, s.handleSync))
+	mux.HandleFunc("POST /pause", s.require(roleAdmin, s.handlePause))
+	mux.HandleFunc("POST /resume", s.require(roleAdmin, s.handlePause))
+	if s.reload != nil {
+		mux.HandleFunc("POST /reload", s.require(roleAdmin, s.handleReload))
+	}
+	return mux
+}
+
+// Function to answer with a status and a JSON payload
+func writeControl(w http.ResponseWriter, status int, payload any) {
+	// Set before the status, which writeJSON comes after
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, payload)
+}
+
+// Function to get the role of the key a request carries, as a bearer token or in the trigger
+// header, or 0 without a valid one. Every key is compared so the time taken tells nothing.
+func (s *controlServer) role(r *http.Request) controlRole {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		key = r.Header.Get(triggerSecretHeader)
+	}
+	var role controlRole
+	if key == "" {
+		return role
+	}
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.key)) == 1 {
+			role = max(role, k.role)
+		}
+	}
+	return role
+}
+
+// Function to only let through the requests with a key of at least the given role
+func (s *controlServer) require(role controlRole, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch got := s.role(r); {
+		case got == 0:
+			slog.Warn("Rejected a control request with a wrong or missing key", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeControl(w, http.StatusUnauthorized, controlResponse{Error: "invalid or missing key"})
+		case got < role:
+			slog.Warn("Rejected a control request beyond the role of its key", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+			writeControl(w, http.StatusForbidden, controlResponse{Error: "the key isn't allowed to " + r.Method + " " + r.URL.Path})
+		default:
+			next(w, r)
+		}
+	}
+}
+
+// Function to run a sync unless the syncs are paused, waiting for the one running or, without
+// wait, giving up on it
+func (s *controlServer) runSync(wait bool) (runSummary, error) {
+	if wait {
+		s.busy.Lock()
+	} else if !s.busy.TryLock() {
+		return runSummary{}, errSyncBusy
+	}
+	defer s.busy.Unlock()
+
+	s.mu.Lock()
+	if s.paused {
+		s.mu.Unlock()
+		return runSummary{}, errSyncPaused
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	summary, err := s.perform()
+	s.mu.Lock()
+	s.running, s.last = false, &summary
+	s.mu.Unlock()
+	return summary, err
+}
+
+// Function to change where the status reads the run history from, after the daemon reloaded its settings
+func (s *controlServer) setHistoryPath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.historyPath = path
+}
+
+// Function to record when the next scheduled sync is, for the status
+func (s *controlServer) setNext(next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = next
+}
+
+func (s *controlServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := controlStatus{Running: s.running, Paused: s.paused, LastRun: s.last}
+	if !s.next.IsZero() {
+		next := s.next
+		status.NextSync = &next
+	}
+	historyPath := s.historyPath
+	s.mu.Unlock()
+
+	// Before the first run of the process, the latest one comes from the run history
+	if status.LastRun == nil && historyPath != "" {
+		history, err := loadRunHistory(historyPath)
+		if err != nil {
+			slog.Warn("Could not read the run history", "path", historyPath, "error", err)
+		} else if len(history) > 0 {
+			status.LastRun = &history[len(history)-1]
+		}
+	}
+	writeControl(w, http.StatusOK, status)
+}
+
+// Function to run a sync and answer with its outcome once it's over
+func (s *controlServer) handleSync(w http.ResponseWriter, r *http.Request) {
+	summary, err := s.runSync(false)
+	switch {
+	case errors.Is(err, errSyncBusy), errors.Is(err, errSyncPaused):
+		writeControl(w, http.StatusConflict, controlResponse{Error: err.Error()})
+	case err != nil:
+		writeControl(w, http.StatusInternalServerError, controlResponse{Error: err.Error(), Summary: &summary})
+	default:
+		writeControl(w, http.StatusOK, controlResponse{OK: true, Summary: &summary})
+	}
+}
+
+// Function to pause or resume the syncs, scheduled and triggered; a running one goes on
+func (s *controlServer) handlePause(w http.ResponseWriter, r *http.Request) {
+	paused := r.URL.Path == "/pause"
+	s.mu.Lock()
+	s.paused = paused
+	s.mu.Unlock()
+	slog.Info("Changed the syncs from the control API", "paused", paused, "remote_addr", r.RemoteAddr)
+	writeControl(w, http.StatusOK, controlResponse{OK: true})
+}
+
+// Function to reload the settings of the daemon, answering once they apply
+func (s *controlServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.reload(); err != nil {
+		writeControl(w, http.StatusInternalServerError, controlResponse{Error: err.Error()})
+		return
+	}
+	slog.Info("Reloaded the settings from the control API", "remote_addr", r.RemoteAddr)
+	writeControl(w, http.StatusOK, controlResponse{OK: true})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+)
+
+// Header carrying the correlation ID of the run to Spotify, which ignores it
+const correlationHeader = "X-Correlation-Id"
+
+// Correlation ID of the process, which the log lines outside of runs carry and its first run is known by
+var processID = newCorrelationID()
+
+// Number of runs started by the process
+var runCount atomic.Int64
+
+type correlationKey struct{}
+
+// Function to generate a short random ID
+func newCorrelationID() string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Function to get the ID of the next run: the process ID for the first one, so a CLI run is
+// known by one ID, and the process ID with the run number for the next ones, e.g. in servers
+func nextRunID() string {
+	if n := runCount.Add(1); n > 1 {
+		return fmt.Sprintf("%s-%d", processID, n)
+	}
+	return processID
+}
+
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// Function to get the correlation ID of the run a context belongs to, or the process ID
+func correlationID(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationKey{}).(string); ok {
+		return id
+	}
+	return processID
+}
+
+// Transport sending the correlation ID of the request's run along with the Spotify requests
+type correlationTransport struct {
+	next http.RoundTripper
+}
+
+func (t correlationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	accounts, _ := url.Parse(refreshTokenURL)
+	if !isAPIRequest(req.URL) && req.URL.Host != accounts.Host {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(correlationHeader, correlationID(req.Context()))
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables giving ctl the URL of the daemon's control API and the key sent to it
+const (
+	controlURLEnv = "SPOTIFY_CONTROL_URL"
+	controlKeyEnv = "SPOTIFY_CONTROL_KEY"
+)
+
+// Requests of the ctl subcommands to the control API
+var ctlRequests = map[string]struct{ method, path string }{
+	"status": {"GET", "/status"},
+	"sync":   {"POST", "/sync"},
+	"reload": {"POST", "/reload"},
+	"pause":  {"POST", "/pause"},
+	"resume": {"POST", "/resume"},
+}
+
+// Function to command a running daemon through its control API, rather than starting another
+// process that fights it over the token and state files
+func runCtl(ctx context.Context, args []string) {
+	if len(args) == 0 || ctlRequests[args[0]].path == "" {
+		fmt.Println("Usage: spotify-cli ctl status|sync|reload|pause|resume [flags]")
+		os.Exit(2)
+	}
+	request := ctlRequests[args[0]]
+
+	flags := flag.NewFlagSet("ctl "+args[0], flag.ExitOnError)
+	defaultURL := "http://localhost:8080"
+	if url := os.Getenv(controlURLEnv); url != "" {
+		defaultURL = url
+	}
+	addr := flags.String("url", defaultURL, "URL of the control API of the daemon, as given to its --control-addr; defaults to $"+controlURLEnv)
+	key := flags.String("key", os.Getenv(controlKeyEnv), "key of the control API with the role the command needs; defaults to $"+controlKeyEnv)
+	format := flags.String("format", "table", "output format of status: "+formatNames())
+	tmpl := flags.String("template", "", "Go template used by --format template")
+	flags.Parse(args[1:])
+
+	req, err := http.NewRequestWithContext(ctx, request.method, strings.TrimSuffix(*addr, "/")+request.path, nil)
+	if err != nil {
+		fmt.Println("Error", err)
+		os.Exit(1)
+	}
+	if *key != "" {
+		req.Header.Set("Authorization", "Bearer "+*key)
+	}
+	// Not the Spotify client: a triggered sync is answered once it's over, however long it takes
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Println("Error reaching the daemon:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		fmt.Printf("Error: %s doesn't serve %s; is it a daemon with --control-addr?\n", *addr, request.path)
+		os.Exit(1)
+	}
+
+	if args[0] == "status" && resp.StatusCode == http.StatusOK {
+		var status controlStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			fmt.Println("Error reading the status:", err)
+			os.Exit(1)
+		}
+		if err := writeReport(os.Stdout, *format, *tmpl, controlStatusReport(status)); err != nil {
+			fmt.Println("Error writing status:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var response controlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		fmt.Println("Error reading the answer of the daemon:", err)
+		os.Exit(1)
+	}
+	if !response.OK {
+		fmt.Println("Error", response.Error)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "sync":
+		fmt.Printf("Synced %d playlist(s), adding %d track(s).\n", len(response.Summary.Playlists), response.Summary.TracksAdded)
+	case "reload":
+		fmt.Println("Reloaded the settings of the daemon.")
+	case "pause":
+		fmt.Println("Paused the syncs; a running one goes on.")
+	case "resume":
+		fmt.Println("Resumed the syncs.")
+	}
+}
+
+func controlStatusReport(status controlStatus) report {
+	row := []string{strconv.FormatBool(status.Running), strconv.FormatBool(status.Paused), "", "", "", ""}
+	if status.NextSync != nil {
+		row[2] = status.NextSync.Format(time.RFC3339)
+	}
+	if run := status.LastRun; run != nil {
+		row[3], row[4], row[5] = run.FinishedAt.Format(time.RFC3339), strconv.Itoa(run.TracksAdded), run.Error
+	}
+	return report{Columns: []string{"RUNNING", "PAUSED", "NEXT SYNC", "LAST RUN", "TRACKS ADDED", "ERROR"}, Rows: [][]string{row}, Data: status}
+}
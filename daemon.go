@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Flags of the daemon, which can also come from the daemon section of the config file
+type daemonFlags struct {
+	every     *time.Duration
+	cron      *string
+	runNow    *bool
+	runLogDir *string
+	// Addresses of the control API and the Prometheus metrics, off when empty
+	controlAddr *string
+	metricsAddr *string
+}
+
+var daemonFlagNames = map[string]bool{"every": true, "cron": true, "run-now": true, "run-log-dir": true, "control-addr": true, "metrics-addr": true}
+
+func registerDaemonFlags(flags *flag.FlagSet) daemonFlags {
+	return daemonFlags{
+		every:       flags.Duration("every", 6*time.Hour, "time between two syncs"),
+		cron:        flags.String("cron", "", "cron expression of the sync times in the --timezone zone, e.g. '0 */6 * * *' or @daily, instead of --every"),
+		runNow:      flags.Bool("run-now", true, "sync once at startup rather than waiting for the first scheduled time; a scheduled sync missed while the daemon was down runs at startup either way"),
+		runLogDir:   flags.String("run-log-dir", "", "directory also receiving the log of every run, one file per run"),
+		controlAddr: flags.String("control-addr", "", "address of the control API that ctl talks to: the status, triggered syncs, pausing and reloading the settings, with the keys of "+controlKeysEnv),
+		metricsAddr: flags.String("metrics-addr", "", "address serving the Prometheus metrics of the syncs on /metrics, e.g. :9090"),
+	}
+}
+
+// Function to build the schedule from the daemon flags, the ones not given on the command line
+// coming from the daemon section of the config file or the environment
+func (df daemonFlags) schedule(flags *flag.FlagSet, clock Clock) (schedule, error) {
+	unknown, errs := applySettings(flags, daemonSettings(), explicitFlags(flags), daemonFlagNames)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("applying the config file: %w", errs[0])
+	}
+	for _, key := range unknown {
+		slog.Warn("Ignoring an unknown daemon setting of the config file", "setting", key, "path", configFile)
+	}
+	return parseSchedule(*df.cron, *df.every, clock)
+}
+
+// Function to get the daemon section of the settings
+func daemonSettings() map[string]any {
+	section, _ := settings["daemon"].(map[string]any)
+	return section
+}
+
+// Function to keep the playlists current from a long-lived process, syncing on a schedule. The
+// settings of the config file apply again when it changes, between two runs.
+func runDaemon(ctx context.Context, args []string) {
+	cfg, err := loadDaemonConfig(args, flag.ExitOnError)
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if *cfg.flags.runLogDir != "" {
+		if err := os.MkdirAll(*cfg.flags.runLogDir, 0o755); err != nil {
+			fmt.Println("Error creating the run log directory:", err)
+			return
+		}
+	}
+
+	// Runs are let finish on the first interrupt, a second one stopping the process right away.
+	// The settings only change while no run holds control.busy.
+	runCtx := context.WithoutCancel(ctx)
+	control := &controlServer{historyPath: cfg.opts.HistoryPath}
+	control.perform = func() (runSummary, error) { return daemonRun(runCtx, cfg.opts, *cfg.flags.runLogDir) }
+	// The reloads asked through the control API are made by the scheduling loop, between two waits
+	reloadRequests := make(chan chan error)
+	control.reload = func() error {
+		reply := make(chan error, 1)
+		select {
+		case reloadRequests <- reply:
+		case <-ctx.Done():
+			return errors.New("the daemon is shutting down")
+		}
+		return <-reply
+	}
+	if *cfg.flags.controlAddr != "" {
+		if control.keys, err = loadControlKeys(); err != nil {
+			fmt.Println("Error", err)
+			return
+		}
+		defer serveInBackground(ctx, "control API", *cfg.flags.controlAddr, control.handler())()
+	}
+	if *cfg.flags.metricsAddr != "" {
+		if err := seedSyncMetrics("", cfg.opts.HistoryPath); err != nil {
+			slog.Warn("Could not read the run history", "path", cfg.opts.HistoryPath, "error", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /metrics", handleMetrics)
+		defer serveInBackground(ctx, "metrics", *cfg.flags.metricsAddr, mux)()
+	}
+	go func() {
+		<-ctx.Done()
+		slog.Info("Shutting down once the current run, if any, is over; interrupt again to stop now")
+	}()
+
+	// Function to read the config file again and apply it once no run is going on, returning
+	// whether the schedule changed. A config that doesn't load leaves the settings as they were.
+	reload := func() (bool, error) {
+		control.busy.Lock()
+		defer control.busy.Unlock()
+		values, err := loadConfig(configFile)
+		if err != nil {
+			return false, err
+		}
+		previous := settings
+		settings = values
+		updated, err := loadDaemonConfig(args, flag.ContinueOnError)
+		if err != nil {
+			settings = previous
+			return false, err
+		}
+		scheduleChanged := logConfigChanges(cfg, updated)
+		cfg = updated
+		cfg.applyNaming()
+		control.setHistoryPath(cfg.opts.HistoryPath)
+		return scheduleChanged, nil
+	}
+	configChanged, err := watchConfigFile(ctx, configFile)
+	if err != nil {
+		slog.Warn("Not watching the config file; use ctl reload to apply its changes", "path", configFile, "error", err)
+	}
+
+	// A sync missed while the daemon was down runs right away, and catches up on the periods
+	// that ended meanwhile, creating their playlists
+	next := cfg.opts.Clock.Now()
+	if !*cfg.flags.runNow {
+		if missed, ok := missedScheduledSync(cfg.opts.StatePath, cfg.sched, next); ok {
+			slog.Info("Catching up on the sync missed while the daemon was down", "missed", missed.Format(time.RFC3339))
+		} else {
+			next = cfg.sched.Next(next)
+		}
+	}
+	// Function to reload the settings, moving the next sync when the schedule changed
+	reloadSettings := func() error {
+		scheduleChanged, err := reload()
+		if err != nil {
+			slog.Warn("Could not reload the config file; keeping the settings", "path", configFile, "error", err)
+			return err
+		}
+		if scheduleChanged {
+			next = cfg.sched.Next(cfg.opts.Clock.Now())
+		}
+		return nil
+	}
+	for {
+		control.setNext(next)
+		if wait := next.Sub(cfg.opts.Clock.Now()); wait > 0 {
+			slog.Info("Waiting for the next sync", "next", next.Format(time.RFC3339))
+			select {
+			case <-time.After(wait):
+			case <-configChanged:
+				reloadSettings()
+				continue
+			case reply := <-reloadRequests:
+				reply <- reloadSettings()
+				continue
+			case <-ctx.Done():
+				slog.Info("Daemon stopped")
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			slog.Info("Daemon stopped")
+			return
+		}
+
+		// A sync triggered through the control API is waited for
+		if _, err := control.runSync(true); errors.Is(err, errSyncPaused) {
+			slog.Info("Skipping the scheduled sync; the syncs are paused")
+		}
+		// Times missed while the run was going on are skipped
+		next = cfg.sched.Next(cfg.opts.Clock.Now())
+	}
+}
+
+// Function to get the scheduled sync missed since the last one recorded in the state, if any. A
+// state that never synced has nothing to catch up on.
+func missedScheduledSync(statePath string, sched schedule, now time.Time) (time.Time, bool) {
+	state, err := loadState(statePath)
+	if err != nil {
+		slog.Warn("Could not read the state to catch up on missed syncs", "path", statePath, "error", err)
+		return time.Time{}, false
+	}
+	if state.LastSyncedAt.IsZero() {
+		return time.Time{}, false
+	}
+	due := sched.Next(state.LastSyncedAt.In(now.Location()))
+	return due, due.Before(now)
+}
+
+// Function to run one sync of the daemon, logging its outcome and, with --run-log-dir, copying its
+// log into a file of its own
+func daemonRun(ctx context.Context, opts syncOptions, runLogDir string) (runSummary, error) {
+	started := time.Now()
+	if runLogDir != "" {
+		path := filepath.Join(runLogDir, "run-"+started.Format("20060102T150405")+".log")
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			slog.Warn("Could not open the run log", "path", path, "error", err)
+		} else {
+			defer file.Close()
+			defer logOutput.tee(file)()
+		}
+	}
+
+	summary, err := runSyncWithHooks(ctx, opts)
+	ctx = withCorrelationID(ctx, summary.RunID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Run failed", "error", err, "duration", time.Since(started))
+		return summary, err
+	}
+	slog.InfoContext(ctx, "Run finished", "tracks_added", summary.TracksAdded, "duration", time.Since(started))
+	return summary, nil
+}
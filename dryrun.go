@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+const skippedPlaylistDescription = "Tracks skipped by the Monthly Playlist filters"
+
+func skippedPlaylistName(playlistName string) string {
+	return playlistName + " — Skipped"
+}
+
+// Function to print what a sync would change instead of changing it: the tracks missing from
+// the monthly playlist (all of them when it doesn't exist yet) and from the skipped companion.
+// The planned tracks are reported as added. name is the monthly name the state keeps the playlist under.
+func planTracks(ctx context.Context, accessToken, name string, result syncResult, opts syncOptions, state *syncState) (syncResult, error) {
+	var err error
+	result.Added, err = plannedTracks(ctx, accessToken, result.PlaylistID, result.Kept)
+	if err != nil {
+		return result, fmt.Errorf("reading playlist: %w", err)
+	}
+	printPlan(os.Stdout, result.PlaylistName, result.Added)
+	if opts.Prune {
+		result.Removed, err = staleTracks(ctx, accessToken, result.PlaylistID, result.Kept, state.addedTo(result.PlaylistID))
+		if err != nil {
+			return result, fmt.Errorf("reading playlist: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "Dry run: would remove %d track(s) from %s.\n", len(result.Removed), result.PlaylistName)
+		for _, track := range result.Removed {
+			fmt.Fprintf(os.Stdout, "  %s by %s (%s)\n", track.Name, artistNames(track), trackURI(track))
+		}
+	}
+	if opts.DescriptionPolicy != descriptionNever {
+		fmt.Printf("Dry run: would update the description of %s (%s policy).\n", result.PlaylistName, opts.DescriptionPolicy)
+	}
+
+	if opts.CollectSkipped && len(result.Skipped) > 0 {
+		title := skippedPlaylistName(result.PlaylistName)
+		playlistID, err := resolvePlaylist(ctx, accessToken, state, skippedPlaylistName(name), title, skippedPlaylistDescription, opts.OnDeletedPlaylist, true)
+		if err != nil {
+			return result, fmt.Errorf("finding skipped playlist: %w", err)
+		}
+		skipped, err := plannedTracks(ctx, accessToken, playlistID, skippedTracks(result.Skipped))
+		if err != nil {
+			return result, fmt.Errorf("reading skipped playlist: %w", err)
+		}
+		printPlan(os.Stdout, title, skipped)
+	}
+	return result, nil
+}
+
+func plannedTracks(ctx context.Context, accessToken, playlistID string, tracks []Track) ([]Track, error) {
+	if playlistID == "" {
+		return tracks, nil
+	}
+	return missingFromPlaylist(ctx, accessToken, playlistID, tracks)
+}
+
+func printPlan(w io.Writer, playlistName string, tracks []Track) {
+	fmt.Fprintf(w, "Dry run: would add %d track(s) to %s.\n", len(tracks), playlistName)
+	for _, track := range tracks {
+		fmt.Fprintf(w, "  %s by %s (%s)\n", track.Name, artistNames(track), trackURI(track))
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const e2ePlaylistPrefix = "spotify-like-songs-e2e"
+
+// Function to run the real sync twice against a designated test account, with a state file of its
+// own, and check the results through the API. It only runs when SPOTIFY_E2E_USER_ID is set and
+// matches the authenticated account, so it never touches a real library by accident.
+func runE2E(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("e2e", flag.ExitOnError)
+	keep := flags.Bool("keep", false, "keep the test playlist instead of cleaning it up")
+	flags.Parse(args)
+
+	testUserID := os.Getenv("SPOTIFY_E2E_USER_ID")
+	if testUserID == "" {
+		fmt.Println("Skipping e2e: set SPOTIFY_E2E_USER_ID to the ID of the test account")
+		return
+	}
+
+	if err := e2eSync(ctx, testUserID, *keep); err != nil {
+		fmt.Println("e2e failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("e2e passed")
+}
+
+func e2eSync(ctx context.Context, testUserID string, keep bool) error {
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	accessToken := token.AccessToken
+
+	profile, err := getCurrentUser(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("getting current user: %w", err)
+	}
+	if profile.ID != testUserID {
+		return fmt.Errorf("authenticated as %q, expected the test account %q", profile.ID, testUserID)
+	}
+
+	// The month of the newest liked song is synced, so the test has songs whatever the date
+	liked, err := fetchLikedSongs(ctx, accessToken, time.Now())
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	if len(liked.Items) == 0 {
+		return fmt.Errorf("the test account has no liked songs")
+	}
+	clock := fixedClock{t: liked.Items[0].AddedAt.In(monthZone)}
+
+	dir, err := os.MkdirTemp("", "spotify-cli-e2e")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// The playlists are named with the test prefix, never like the real monthly ones
+	previous := playlistNameTemplate
+	defer func() { playlistNameTemplate = previous }()
+	stamp := time.Now().Format("20060102-150405")
+	if playlistNameTemplate, err = parseNameTemplate(e2ePlaylistPrefix+" "+stamp+" {{.Year}}-{{printf \"%02d\" .Month}}", playlistNameLocale); err != nil {
+		return fmt.Errorf("parsing the naming template: %w", err)
+	}
+
+	cache, err := loadTrackCache("", defaultCacheTTL)
+	if err != nil {
+		return err
+	}
+	opts := syncOptions{
+		Clock:             clock,
+		StatePath:         filepath.Join(dir, "state.json"),
+		Description:       "Created by the spotify-like-songs e2e test",
+		DescriptionPolicy: descriptionNever,
+		OnDeletedPlaylist: deletedPlaylistAbort,
+		SourcePriority:    []string{provenanceLikedSong},
+		SourceDedupe:      dedupeByID,
+		Cache:             cache,
+	}
+
+	first, err := runSyncWithHooks(ctx, opts)
+	for _, playlist := range first.Playlists {
+		if playlist.ID == "" || keep {
+			continue
+		}
+		defer func() {
+			// Cleaned up even when the test failed or was interrupted
+			if err := unfollowPlaylist(context.WithoutCancel(ctx), accessToken, playlist.ID); err != nil {
+				slog.WarnContext(ctx, "Could not clean up the test playlist", "playlist_id", playlist.ID, "error", err)
+			}
+		}()
+	}
+	if err != nil {
+		return fmt.Errorf("first sync: %w", err)
+	}
+	if len(first.Playlists) != 1 {
+		return fmt.Errorf("first sync synced %d playlist(s), expected 1", len(first.Playlists))
+	}
+	synced := first.Playlists[0]
+	if synced.TracksAdded == 0 || synced.TracksAdded != synced.LikedSongs {
+		return fmt.Errorf("first sync added %d of the %d liked song(s)", synced.TracksAdded, synced.LikedSongs)
+	}
+
+	playlist, err := apiClient(accessToken).Playlist(ctx, synced.ID, "id,name")
+	if err != nil {
+		return fmt.Errorf("reading the playlist: %w", err)
+	}
+	if !strings.HasPrefix(playlist.Name, e2ePlaylistPrefix) {
+		return fmt.Errorf("the playlist is named %q, expected the %s prefix", playlist.Name, e2ePlaylistPrefix)
+	}
+	tracks, err := getPlaylistTracks(ctx, accessToken, synced.ID)
+	if err != nil {
+		return fmt.Errorf("reading the playlist tracks: %w", err)
+	}
+	if len(tracks) != synced.TracksAdded {
+		return fmt.Errorf("the playlist holds %d track(s), expected %d", len(tracks), synced.TracksAdded)
+	}
+
+	state, err := loadState(opts.StatePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	if state.Playlists[synced.Name] != synced.ID {
+		return fmt.Errorf("the state records playlist %q for %s, expected %q", state.Playlists[synced.Name], synced.Name, synced.ID)
+	}
+
+	// A second sync from the same state must find the same playlist and add nothing
+	second, err := runSyncWithHooks(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("second sync: %w", err)
+	}
+	if len(second.Playlists) != 1 || second.Playlists[0].ID != synced.ID {
+		return fmt.Errorf("second sync didn't sync the playlist of the first one")
+	}
+	if second.TracksAdded != 0 {
+		return fmt.Errorf("second sync added %d duplicate track(s)", second.TracksAdded)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const oEmbedURL = "https://open.spotify.com/oembed"
+
+// Function to print a ready-to-paste embed snippet for a monthly playlist
+func runEmbed(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("embed", flag.ExitOnError)
+	month := flags.String("month", nowInMonthZone().Format("2006-01"), "month of the playlist (YYYY-MM)")
+	playlistID := flags.String("playlist", "", "playlist ID, instead of looking up the monthly playlist")
+	style := flags.String("style", "iframe", "snippet style: iframe or oembed (the JSON returned by Spotify's oEmbed API)")
+	height := flags.Int("height", 352, "height of the iframe in pixels")
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping track of past syncs")
+	flags.Parse(args)
+
+	id := *playlistID
+	if id == "" {
+		var err error
+		id, err = findMonthlyPlaylist(ctx, *month, *statePath)
+		if err != nil {
+			fmt.Println("Error finding playlist:", err)
+			return
+		}
+	}
+
+	switch *style {
+	case "iframe":
+		fmt.Println(embedIframe(id, *height))
+	case "oembed":
+		if err := writeOEmbed(os.Stdout, id); err != nil {
+			fmt.Println("Error getting oEmbed:", err)
+		}
+	default:
+		fmt.Printf("Unknown style %q, expected iframe or oembed\n", *style)
+	}
+}
+
+// Function to find the ID of a month's playlist, from the state file or by searching the user's playlists
+func findMonthlyPlaylist(ctx context.Context, month, statePath string) (string, error) {
+	t, err := time.ParseInLocation("2006-01", month, monthZone)
+	if err != nil {
+		return "", fmt.Errorf("expected a month like 2025-02: %w", err)
+	}
+	name := periodPlaylistName(t)
+
+	state, err := loadState(statePath)
+	if err != nil {
+		return "", err
+	}
+	if id := state.Playlists[name]; id != "" {
+		return id, nil
+	}
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer apps.release()
+	playlist, found, err := searchPlaylist(ctx, token.AccessToken, name)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("there is no playlist named %s", name)
+	}
+	return playlist.ID, nil
+}
+
+func embedIframe(playlistID string, height int) string {
+	return fmt.Sprintf(`<iframe style="border-radius:12px" src="%s/embed/playlist/%s" width="100%%" height="%d" frameBorder="0" allowfullscreen="" allow="autoplay; clipboard-write; encrypted-media; fullscreen; picture-in-picture" loading="lazy"></iframe>`,
+		openSpotifyURL, playlistID, height)
+}
+
+func writeOEmbed(w io.Writer, playlistID string) error {
+	resp, err := httpClient.Get(oEmbedURL + "?url=" + url.QueryEscape(playlistURL(playlistID)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oEmbed returned %s", resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eduardohitek/spotify-cli/spotify"
+)
+
+// A liked song or playlist item as exported
+type exportedTrack struct {
+	AddedAt    time.Time `json:"added_at" parquet:"added_at,timestamp(millisecond)"`
+	AddedBy    string    `json:"added_by,omitempty" parquet:"added_by"`
+	Source     string    `json:"source,omitempty" parquet:"source"`
+	ID         string    `json:"id" parquet:"id"`
+	Name       string    `json:"name" parquet:"name"`
+	Artists    []string  `json:"artists" parquet:"artists,list"`
+	DurationMs int       `json:"duration_ms" parquet:"duration_ms"`
+	Explicit   bool      `json:"explicit" parquet:"explicit"`
+	URI        string    `json:"uri" parquet:"uri"`
+}
+
+// A track as exported for sharing: its metadata only, nothing about the account it came from
+type anonymizedTrack struct {
+	ID         string   `json:"id" parquet:"id"`
+	Name       string   `json:"name" parquet:"name"`
+	Artists    []string `json:"artists" parquet:"artists,list"`
+	DurationMs int      `json:"duration_ms" parquet:"duration_ms"`
+	Explicit   bool     `json:"explicit" parquet:"explicit"`
+	URI        string   `json:"uri" parquet:"uri"`
+}
+
+// Function to export the whole liked library, newest first, or the tracks of a playlist
+func runExport(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "csv", "output format: "+formatNames())
+	tmpl := flags.String("template", "", "Go template used by --format template")
+	fields := flags.String("fields", "", "comma-separated fields to include, by JSON path (e.g. name,artists,added_at)")
+	output := flags.String("output", "-", "file to write the export to (\"-\" for stdout)")
+	month := flags.String("month", "", "export the monthly playlist of this month (YYYY-MM) instead of the liked library")
+	playlistID := flags.String("playlist", "", "export this playlist instead of the liked library")
+	anonymize := flags.Bool("anonymize", false, "keep only track metadata, dropping who added each track and when, for sharing")
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping track of past syncs")
+	flags.Parse(args)
+
+	if err := checkFieldsFormat(*fields, *format); err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		fmt.Println("Error getting access token:", err)
+		return
+	}
+	defer apps.release()
+
+	id := *playlistID
+	if id == "" && *month != "" {
+		id, err = findMonthlyPlaylist(ctx, *month, *statePath)
+		if err != nil {
+			fmt.Println("Error finding playlist:", err)
+			return
+		}
+	}
+
+	features := readOnlyFeatures()
+	if id != "" {
+		features = []feature{featureReadPlaylists}
+	}
+	if err := checkScopes(token, features...); err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	state, err := loadState(*statePath)
+	if err != nil {
+		fmt.Println("Error loading state:", err)
+		return
+	}
+	// Playlist tracks are liked songs unless the state knows better, except in playlists the tool
+	// doesn't manage
+	sources, defaultSource := state.Provenance, provenanceLikedSong
+	if id == "" {
+		sources = nil
+	} else if !managedPlaylist(state, id) {
+		defaultSource = ""
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Println("Error creating export file:", err)
+			return
+		}
+		defer file.Close()
+		w = file
+	}
+	stream, err := newReportStream(w, *format, *tmpl)
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	// Each page is written before the next one is read, so even a huge library is never held
+	// whole in memory with the csv, json and parquet formats
+	writePage := func(items []spotify.PlaylistTrack) error {
+		r, err := selectFields(exportReport(items, *anonymize, sources, defaultSource), *fields)
+		if err != nil {
+			return err
+		}
+		if err := stream.write(r); err != nil {
+			return fmt.Errorf("writing export: %w", err)
+		}
+		return nil
+	}
+	if id != "" {
+		err = eachPlaylistItemsPage(ctx, token.AccessToken, id, "", writePage)
+	} else {
+		err = eachLikedSongsPage(ctx, token.AccessToken, time.Time{}, func(page LikedSongsSearchResponse) error {
+			items := make([]spotify.PlaylistTrack, 0, len(page.Items))
+			for _, song := range page.Items {
+				items = append(items, spotify.PlaylistTrack{AddedAt: song.AddedAt, Track: song.Track})
+			}
+			return writePage(items)
+		})
+	}
+	if err != nil {
+		fmt.Println("Error exporting:", err)
+		return
+	}
+	if err := stream.close(); err != nil {
+		fmt.Println("Error writing export:", err)
+	}
+}
+
+func exportReport(items []spotify.PlaylistTrack, anonymize bool, sources map[string]string, defaultSource string) report {
+	if anonymize {
+		return anonymizedReport(items)
+	}
+
+	tracks := make([]exportedTrack, 0, len(items))
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		track := exportedTrack{
+			AddedAt:    item.AddedAt,
+			AddedBy:    item.AddedBy.ID,
+			Source:     defaultSource,
+			ID:         item.Track.ID,
+			Name:       item.Track.Name,
+			Artists:    artistList(item.Track),
+			DurationMs: item.Track.DurationMs,
+			Explicit:   item.Track.Explicit,
+			URI:        trackURI(item.Track),
+		}
+		if source, ok := sources[track.ID]; ok {
+			track.Source = source
+		}
+		tracks = append(tracks, track)
+		rows = append(rows, []string{
+			track.AddedAt.Format(time.RFC3339), track.AddedBy, track.Source, track.ID, track.Name, strings.Join(track.Artists, ", "),
+			strconv.Itoa(track.DurationMs), strconv.FormatBool(track.Explicit), track.URI,
+		})
+	}
+	return report{
+		Columns: []string{"ADDED_AT", "ADDED_BY", "SOURCE", "ID", "NAME", "ARTISTS", "DURATION_MS", "EXPLICIT", "URI"},
+		Rows:    rows,
+		Data:    tracks,
+	}
+}
+
+// Function to tell whether the tool manages the playlist, as one of the monthly or skipped playlists
+func managedPlaylist(state syncState, playlistID string) bool {
+	for _, id := range state.Playlists {
+		if id == playlistID {
+			return true
+		}
+	}
+	return false
+}
+
+func anonymizedReport(items []spotify.PlaylistTrack) report {
+	tracks := make([]anonymizedTrack, 0, len(items))
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		track := anonymizedTrack{
+			ID:         item.Track.ID,
+			Name:       item.Track.Name,
+			Artists:    artistList(item.Track),
+			DurationMs: item.Track.DurationMs,
+			Explicit:   item.Track.Explicit,
+			URI:        trackURI(item.Track),
+		}
+		tracks = append(tracks, track)
+		rows = append(rows, []string{
+			track.ID, track.Name, strings.Join(track.Artists, ", "),
+			strconv.Itoa(track.DurationMs), strconv.FormatBool(track.Explicit), track.URI,
+		})
+	}
+	return report{
+		Columns: []string{"ID", "NAME", "ARTISTS", "DURATION_MS", "EXPLICIT", "URI"},
+		Rows:    rows,
+		Data:    tracks,
+	}
+}
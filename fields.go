@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Function to check that --fields can be used with the output format. The parquet schema comes
+// from the typed rows of a report, which narrowing them to some fields turns into plain maps.
+func checkFieldsFormat(fields, format string) error {
+	if fields != "" && format == "parquet" {
+		return fmt.Errorf("--fields can't be used with --format parquet")
+	}
+	return nil
+}
+
+// Function to narrow a report to the given fields, named by their JSON paths (e.g. name,
+// artists.name,added_at). Paths go through lists, so artists.name is the list of the artist
+// names. Each element of the data becomes a row, keyed by the paths in the json and yaml formats.
+func selectFields(r report, fields string) (report, error) {
+	if fields == "" {
+		return r, nil
+	}
+	paths := strings.Split(fields, ",")
+	for i := range paths {
+		paths[i] = strings.TrimSpace(paths[i])
+	}
+
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return r, err
+	}
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return r, err
+	}
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+
+	selected := report{Columns: make([]string, len(paths))}
+	rows := []map[string]any{}
+	for i, path := range paths {
+		selected.Columns[i] = strings.ToUpper(path)
+	}
+	for _, item := range items {
+		row := make([]string, len(paths))
+		values := map[string]any{}
+		for i, path := range paths {
+			v, err := fieldValue(item, strings.Split(path, "."))
+			if err != nil {
+				return r, fmt.Errorf("selecting %s: %w", path, err)
+			}
+			row[i] = fieldText(v)
+			values[path] = v
+		}
+		selected.Rows = append(selected.Rows, row)
+		rows = append(rows, values)
+	}
+	selected.Data = rows
+	return selected, nil
+}
+
+func fieldValue(value any, path []string) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", path[0])
+		}
+		return fieldValue(child, path[1:])
+	case []any:
+		values := make([]any, 0, len(v))
+		for _, item := range v {
+			child, err := fieldValue(item, path)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, child)
+		}
+		return values, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%q is not an object", path[0])
+}
+
+func fieldText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			texts = append(texts, fieldText(item))
+		}
+		return strings.Join(texts, ", ")
+	case map[string]any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Track filters applied to the liked songs before they are added to the playlist
+type trackFilters struct {
+	SkipExplicit    bool
+	MaxDuration     time.Duration
+	ExcludedArtists []string
+	// Compiled expr-lang predicate; tracks for which it evaluates to true are skipped
+	SkipIf *vm.Program
+	// Filter rules every track must satisfy to be kept
+	Rules []filterRule
+
+	// Lowercased excluded artists, built once per applyFilters call
+	excluded map[string]bool
+}
+
+// An expr-lang filter rule, e.g. `track.DurationMs > 90000 && !track.Explicit`
+type filterRule struct {
+	Source  string
+	Program *vm.Program
+}
+
+// Function to compile the filter rules, failing on the first invalid one
+func compileFilterRules(sources []string) ([]filterRule, error) {
+	var rules []filterRule
+	for _, source := range sources {
+		program, err := compileFilterExpr(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter rule %q: %w", source, err)
+		}
+		rules = append(rules, filterRule{Source: source, Program: program})
+	}
+	return rules, nil
+}
+
+// Variables available to filter expressions
+type filterEnv struct {
+	Track  Track    `expr:"track"`
+	Banned []string `expr:"banned"`
+}
+
+// Function to compile a filter expression, so invalid expressions are reported before anything is synced
+func compileFilterExpr(source string) (*vm.Program, error) {
+	if source == "" {
+		return nil, nil
+	}
+	return expr.Compile(source, expr.Env(filterEnv{}), expr.AsBool())
+}
+
+// A track rejected by the filters, along with the reason why
+type skippedTrack struct {
+	Track  Track
+	Reason string
+}
+
+// Function to split a comma-separated flag value, dropping empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Function to split the tracks into the ones that pass the filters and the ones that were skipped
+func applyFilters(tracks []Track, filters trackFilters) ([]Track, []skippedTrack) {
+	filters.excluded = make(map[string]bool, len(filters.ExcludedArtists))
+	for _, artist := range filters.ExcludedArtists {
+		filters.excluded[strings.ToLower(artist)] = true
+	}
+
+	kept := make([]Track, 0, len(tracks))
+	var skipped []skippedTrack
+	for _, track := range tracks {
+		if reason := filters.rejectReason(track); reason != "" {
+			slog.Info("Skipping the track", "track_id", track.ID, "track", track.Name, "artists", artistNames(track), "reason", reason)
+			skipped = append(skipped, skippedTrack{Track: track, Reason: reason})
+			continue
+		}
+		kept = append(kept, track)
+	}
+	return kept, skipped
+}
+
+func (f trackFilters) rejectReason(track Track) string {
+	if f.SkipExplicit && track.Explicit {
+		return "explicit"
+	}
+	if f.MaxDuration > 0 && track.Duration() > f.MaxDuration {
+		return fmt.Sprintf("longer than %s", f.MaxDuration)
+	}
+	for _, artist := range track.Artists {
+		if f.excluded[strings.ToLower(artist.Name)] {
+			return "excluded artist " + artist.Name
+		}
+	}
+	env := filterEnv{Track: track, Banned: f.ExcludedArtists}
+	if f.SkipIf != nil {
+		skip, err := expr.Run(f.SkipIf, env)
+		if err != nil {
+			slog.Warn("Could not evaluate the filter expression", "track_id", track.ID, "track", track.Name, "error", err)
+			return ""
+		}
+		if skip.(bool) {
+			return "matched the filter expression"
+		}
+	}
+	for _, rule := range f.Rules {
+		keep, err := expr.Run(rule.Program, env)
+		if err != nil {
+			slog.Warn("Could not evaluate the filter rule", "rule", rule.Source, "track_id", track.ID, "track", track.Name, "error", err)
+			continue
+		}
+		if !keep.(bool) {
+			return "failed the filter rule " + rule.Source
+		}
+	}
+	return ""
+}
+
+func skippedTracks(skipped []skippedTrack) []Track {
+	tracks := make([]Track, 0, len(skipped))
+	for _, s := range skipped {
+		tracks = append(tracks, s.Track)
+	}
+	return tracks
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/parquet-go/parquet-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Output of a list or report subcommand: tabular rows for the table and csv formats,
+// and the typed value behind them for the json, yaml and template formats
+type report struct {
+	Columns []string
+	Rows    [][]string
+	Data    any
+}
+
+// Function rendering a report to the writer
+type formatter func(w io.Writer, r report, tmpl string) error
+
+// Formatters available to the --format flag of every list/report subcommand
+var formatters = map[string]formatter{
+	"table":    formatTable,
+	"csv":      formatCSV,
+	"json":     formatJSON,
+	"parquet":  formatParquet,
+	"yaml":     formatYAML,
+	"template": formatTemplate,
+}
+
+func formatNames() string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Function to render a report with the named format; tmpl is the Go template used by the template format
+func writeReport(w io.Writer, format, tmpl string, r report) error {
+	f, ok := formatters[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q, expected one of %s", format, formatNames())
+	}
+	return f(w, r, tmpl)
+}
+
+// Writer of a report produced in chunks, e.g. a page of tracks at a time, all the chunks having
+// the same columns and data type
+type reportStream interface {
+	write(chunk report) error
+	close() error
+}
+
+// Formats writing each chunk as it comes, so a long report is never held in memory. The others
+// need every row, e.g. to align the table columns, and get the chunks gathered.
+var streamFormatters = map[string]func(w io.Writer) reportStream{
+	"csv":     func(w io.Writer) reportStream { return &csvStream{w: csv.NewWriter(w)} },
+	"json":    func(w io.Writer) reportStream { return &jsonStream{w: w} },
+	"parquet": func(w io.Writer) reportStream { return &parquetStream{w: w} },
+}
+
+// Function to start writing a report chunk by chunk with the named format
+func newReportStream(w io.Writer, format, tmpl string) (reportStream, error) {
+	if _, ok := formatters[format]; !ok {
+		return nil, fmt.Errorf("unknown format %q, expected one of %s", format, formatNames())
+	}
+	if stream, ok := streamFormatters[format]; ok {
+		return stream(w), nil
+	}
+	return &gatheredStream{w: w, format: format, tmpl: tmpl}, nil
+}
+
+type csvStream struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (s *csvStream) write(chunk report) error {
+	if !s.header {
+		if err := s.w.Write(chunk.Columns); err != nil {
+			return err
+		}
+		s.header = true
+	}
+	if err := s.w.WriteAll(chunk.Rows); err != nil {
+		return err
+	}
+	return s.w.Error()
+}
+
+func (s *csvStream) close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// The elements of the chunks' data make up one JSON array, indented like the json format
+type jsonStream struct {
+	w     io.Writer
+	count int
+}
+
+func (s *jsonStream) write(chunk report) error {
+	rows := reflect.ValueOf(chunk.Data)
+	if rows.Kind() != reflect.Slice {
+		return fmt.Errorf("streaming the json format needs a list, got %s", rows.Type())
+	}
+	for i := 0; i < rows.Len(); i++ {
+		data, err := json.MarshalIndent(rows.Index(i).Interface(), "  ", "  ")
+		if err != nil {
+			return err
+		}
+		separator := ",\n  "
+		if s.count == 0 {
+			separator = "[\n  "
+		}
+		if _, err := io.WriteString(s.w, separator+string(data)); err != nil {
+			return err
+		}
+		s.count++
+	}
+	return nil
+}
+
+func (s *jsonStream) close() error {
+	end := "\n]\n"
+	if s.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(s.w, end)
+	return err
+}
+
+// The Parquet writer is made for the element type of the first chunk's data
+type parquetStream struct {
+	w      io.Writer
+	writer *parquet.Writer
+}
+
+func (s *parquetStream) write(chunk report) error {
+	rows := reflect.ValueOf(chunk.Data)
+	if rows.Kind() != reflect.Slice || rows.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("the parquet format needs tabular data, got %s", rows.Type())
+	}
+	if s.writer == nil {
+		s.writer = parquet.NewWriter(s.w, parquet.SchemaOf(reflect.New(rows.Type().Elem()).Interface()))
+	}
+	for i := 0; i < rows.Len(); i++ {
+		if err := s.writer.Write(rows.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *parquetStream) close() error {
+	if s.writer == nil {
+		return fmt.Errorf("the parquet format got no data")
+	}
+	return s.writer.Close()
+}
+
+// Chunks gathered into one report, written with a format needing all of it on close
+type gatheredStream struct {
+	w      io.Writer
+	format string
+	tmpl   string
+	report report
+	data   reflect.Value
+}
+
+func (s *gatheredStream) write(chunk report) error {
+	s.report.Columns = chunk.Columns
+	s.report.Rows = append(s.report.Rows, chunk.Rows...)
+	rows := reflect.ValueOf(chunk.Data)
+	if rows.Kind() != reflect.Slice {
+		return fmt.Errorf("gathering a report needs list data, got %s", rows.Type())
+	}
+	if !s.data.IsValid() {
+		s.data = reflect.MakeSlice(rows.Type(), 0, rows.Len())
+	}
+	s.data = reflect.AppendSlice(s.data, rows)
+	return nil
+}
+
+func (s *gatheredStream) close() error {
+	if s.data.IsValid() {
+		s.report.Data = s.data.Interface()
+	}
+	return writeReport(s.w, s.format, s.tmpl, s.report)
+}
+
+func formatTable(w io.Writer, r report, _ string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(r.Columns, "\t"))
+	for _, row := range r.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func formatCSV(w io.Writer, r report, _ string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(r.Columns); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(r.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func formatJSON(w io.Writer, r report, _ string) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.Data)
+}
+
+func formatTemplate(w io.Writer, r report, tmpl string) error {
+	if tmpl == "" {
+		return fmt.Errorf("the template format needs a --template")
+	}
+	t, err := template.New("report").Funcs(template.FuncMap{"join": strings.Join}).Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, r.Data)
+}
+
+// The Parquet file has a row per element of the data, which must be a struct or a slice of
+// structs; columns are named by the parquet tags of the struct fields
+func formatParquet(w io.Writer, r report, _ string) error {
+	rows := reflect.ValueOf(r.Data)
+	if rows.Kind() != reflect.Slice {
+		rows = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rows.Type()), 0, 1), rows)
+	}
+	if rows.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("the parquet format needs tabular data, got %s", rows.Type())
+	}
+
+	writer := parquet.NewWriter(w, parquet.SchemaOf(reflect.New(rows.Type().Elem()).Interface()))
+	for i := 0; i < rows.Len(); i++ {
+		if err := writer.Write(rows.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// The YAML is produced from the JSON form of the data, so field names match the json format
+func formatYAML(w io.Writer, r report, _ string) error {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	// Numbers are kept as written, so large integers don't turn into floats
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(yamlNumbers(value)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Function to turn the JSON numbers of a decoded value into YAML number nodes, written as they
+// came rather than as quoted strings
+func yamlNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = yamlNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = yamlNumbers(item)
+		}
+	case json.Number:
+		tag := "!!int"
+		if _, err := v.Int64(); err != nil {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	}
+	return value
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metrics served to Grafana's JSON datasource
+const (
+	metricTracksAdded    = "tracks_added"
+	metricTracksPerMonth = "tracks_per_month"
+	metricFailedRuns     = "failed_runs"
+)
+
+// Body of a /query request from Grafana's JSON datasource
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+}
+
+// Series answered to Grafana, each datapoint being [value, unix milliseconds]
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// Function to serve the run history to Grafana's JSON datasource (or any HTTP client),
+// for a personal listening dashboard
+func runGrafana(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("grafana", flag.ExitOnError)
+	addr := flags.String("addr", ":8081", "address to listen on")
+	historyPath := flags.String("history-file", defaultHistoryPath(), "run history written by sync")
+	tenantsFlag := flags.String("tenants-dir", "", "also serve the metrics of each tenant of the multi-tenant mode in this directory, as <metric>@<tenant ID>")
+	flags.Parse(args)
+	if *tenantsFlag != "" {
+		tenantsDir = *tenantsFlag
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	metrics := []string{metricTracksAdded, metricTracksPerMonth, metricFailedRuns}
+	mux.HandleFunc("POST /search", func(w http.ResponseWriter, r *http.Request) {
+		targets := metrics
+		if *tenantsFlag != "" {
+			tenants, err := listTenants()
+			if err != nil {
+				slog.Error("Could not list the tenants", "error", err)
+			}
+			for _, t := range tenants {
+				for _, metric := range metrics {
+					targets = append(targets, metric+"@"+t.ID)
+				}
+			}
+		}
+		writeJSON(w, targets)
+	})
+	mux.HandleFunc("POST /query", func(w http.ResponseWriter, r *http.Request) {
+		var query grafanaQuery
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		series := []grafanaSeries{}
+		for _, target := range query.Targets {
+			// A tenant's metrics come from its own run history
+			metric, tenantID, ofTenant := strings.Cut(target.Target, "@")
+			path := *historyPath
+			if ofTenant {
+				t, err := loadTenant(tenantID)
+				if err != nil || *tenantsFlag == "" {
+					http.Error(w, "unknown target "+target.Target, http.StatusBadRequest)
+					return
+				}
+				path = t.path("history.jsonl")
+			}
+			history, err := loadRunHistory(path)
+			if err != nil {
+				slog.Error("Could not read the run history", "path", path, "error", err)
+				http.Error(w, "could not read the run history", http.StatusInternalServerError)
+				return
+			}
+			points, ok := historySeries(history, metric)
+			if !ok {
+				http.Error(w, "unknown target "+target.Target, http.StatusBadRequest)
+				return
+			}
+			series = append(series, grafanaSeries{Target: target.Target, Datapoints: inRange(points, query.Range.From, query.Range.To)})
+		}
+		writeJSON(w, series)
+	})
+
+	slog.Info("Serving the run history to Grafana", "addr", *addr)
+	if err := serve(ctx, *addr, mux); err != nil {
+		fmt.Println("Error serving:", err)
+	}
+}
+
+// Function to compute the datapoints of a metric from the run history, oldest first
+func historySeries(history []runSummary, metric string) ([][2]float64, bool) {
+	var points [][2]float64
+	switch metric {
+	case metricTracksAdded:
+		for _, run := range history {
+			points = append(points, [2]float64{float64(run.TracksAdded), float64(run.StartedAt.UnixMilli())})
+		}
+	case metricFailedRuns:
+		for _, run := range history {
+			if run.Error != "" {
+				points = append(points, [2]float64{1, float64(run.StartedAt.UnixMilli())})
+			}
+		}
+	case metricTracksPerMonth:
+		// Months are keyed by their playlist name, which reads back as the first day of the month
+		perMonth := map[time.Time]int{}
+		for _, run := range history {
+			for _, playlist := range run.Playlists {
+				month, err := time.ParseInLocation("Jan'06", playlist.Name, monthZone)
+				if err != nil {
+					continue
+				}
+				perMonth[month] += playlist.TracksAdded
+			}
+		}
+		for month, tracks := range perMonth {
+			points = append(points, [2]float64{float64(tracks), float64(month.UnixMilli())})
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i][1] < points[j][1] })
+	default:
+		return nil, false
+	}
+	return points, true
+}
+
+// Function to keep the datapoints between from and to; a zero bound is open
+func inRange(points [][2]float64, from, to time.Time) [][2]float64 {
+	kept := [][2]float64{}
+	for _, point := range points {
+		at := time.UnixMilli(int64(point[1]))
+		if (!from.IsZero() && at.Before(from)) || (!to.IsZero() && at.After(to)) {
+			continue
+		}
+		kept = append(kept, point)
+	}
+	return kept
+}
+
+func writeJSON(w http.ResponseWriter, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		slog.Warn("Could not write the response", "error", err)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Function to get the default run history location, next to the state file
+func defaultHistoryPath() string {
+	return filepath.Join(filepath.Dir(defaultStatePath()), "history.jsonl")
+}
+
+// Function to load the run history, one JSON run summary per line, oldest first
+func loadRunHistory(path string) ([]runSummary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var runs []runSummary
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var run runSummary
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
+
+func appendRunHistory(path string, summary runSummary) error {
+	line, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Function to record a run in the history, first sending the weekly digest of the previous
+// week when this is the first run of a new one
+func recordRun(ctx context.Context, path string, notifiers []notifier, summary runSummary) {
+	if path == "" {
+		return
+	}
+
+	history, err := loadRunHistory(path)
+	if err != nil {
+		slog.Warn("Could not read the run history", "path", path, "error", err)
+		return
+	}
+	if len(history) > 0 {
+		lastWeek := weekStart(history[len(history)-1].StartedAt.Local())
+		if weekStart(summary.StartedAt.Local()).After(lastWeek) {
+			sendNotification(ctx, notifiers, digestNotification(lastWeek, runsSince(history, lastWeek)))
+		}
+	}
+
+	if err := appendRunHistory(path, summary); err != nil {
+		slog.Warn("Could not record the run in the history", "path", path, "error", err)
+	}
+}
+
+// Function to get the Monday starting the week of t
+func weekStart(t time.Time) time.Time {
+	days := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, t.Location())
+}
+
+func runsSince(history []runSummary, since time.Time) []runSummary {
+	var runs []runSummary
+	for _, run := range history {
+		if !run.StartedAt.Before(since) {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// Function to aggregate the runs of a week into a single notification
+func digestNotification(week time.Time, runs []runSummary) notification {
+	var failed, added int
+	var playlists []string
+	addedTo := map[string]int{}
+	for _, run := range runs {
+		if run.Error != "" {
+			failed++
+		}
+		added += run.TracksAdded
+		for _, playlist := range run.Playlists {
+			if _, ok := addedTo[playlist.Name]; !ok {
+				playlists = append(playlists, playlist.Name)
+			}
+			addedTo[playlist.Name] += playlist.TracksAdded
+		}
+	}
+
+	lines := []string{fmt.Sprintf("%d run(s), %d failed, %d track(s) added.", len(runs), failed, added)}
+	for _, name := range playlists {
+		lines = append(lines, fmt.Sprintf("%s: +%d track(s)", name, addedTo[name]))
+	}
+	return notification{
+		Title:   "Week of " + week.Format("Jan 2") + " in liked songs",
+		Message: strings.Join(lines, "\n"),
+		Failure: failed > 0,
+		Digest:  true,
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// How long a hook command may run before it's killed
+const hookTimeout = 5 * time.Minute
+
+// Outcome of a whole sync run, handed to the hook commands
+type runSummary struct {
+	// Correlation ID of the run, found in the log lines and sent to Spotify
+	RunID string `json:"run_id"`
+	// Tenant synced, in the multi-tenant mode
+	Tenant    string    `json:"tenant,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// Display name of the account synced
+	Owner       string            `json:"owner,omitempty"`
+	FinishedAt  time.Time         `json:"finished_at"`
+	Playlists   []playlistSummary `json:"playlists"`
+	TracksAdded int               `json:"tracks_added"`
+	Error       string            `json:"error,omitempty"`
+	// Whether the run stopped early on --max-api-calls, having saved its progress
+	Partial bool `json:"partial,omitempty"`
+	// Whether the run was a --dry-run, its counts being what a sync would have done
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+type playlistSummary struct {
+	Name          string `json:"name"`
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	LikedSongs    int    `json:"liked_songs"`
+	TracksAdded   int    `json:"tracks_added"`
+	TracksSkipped int    `json:"tracks_skipped"`
+	// Skipped tracks that were already in the playlist, the others being rejected by the filters
+	TracksDuplicate int `json:"tracks_duplicate"`
+	TracksFailed    int `json:"tracks_failed"`
+	TracksRemoved   int `json:"tracks_removed,omitempty"`
+	// Added tracks by provenance
+	Sources map[string]int `json:"sources,omitempty"`
+}
+
+func (s *runSummary) add(result syncResult) {
+	summary := playlistSummary{
+		Name:            result.PlaylistName,
+		ID:              result.PlaylistID,
+		LikedSongs:      len(result.Liked),
+		TracksAdded:     len(result.Added),
+		TracksSkipped:   len(result.Liked) - len(result.Added) - len(result.Failed),
+		TracksDuplicate: max(len(result.Kept)-len(result.Added)-len(result.Failed), 0),
+		TracksFailed:    len(result.Failed),
+		TracksRemoved:   len(result.Removed),
+	}
+	if result.PlaylistID != "" {
+		summary.URL = playlistURL(result.PlaylistID)
+	}
+	for _, track := range result.Added {
+		if summary.Sources == nil {
+			summary.Sources = map[string]int{}
+		}
+		summary.Sources[result.source(track)]++
+	}
+	s.Playlists = append(s.Playlists, summary)
+	s.TracksAdded += len(result.Added)
+}
+
+// Function to describe the outcome of a sync in a short chat message
+func syncOutcomeText(summary runSummary, err error) string {
+	if err != nil {
+		return "Sync failed: " + err.Error()
+	}
+	var lines []string
+	for _, playlist := range summary.Playlists {
+		lines = append(lines, fmt.Sprintf("%s: +%d track(s) %s", ownedPlaylistName(summary.Owner, playlist.Name), playlist.TracksAdded, playlist.URL))
+	}
+	finished := "Sync finished"
+	if summary.Partial {
+		finished = "Sync stopped at the API call budget"
+	}
+	if len(lines) == 0 {
+		return finished + ": nothing to do."
+	}
+	return finished + ".\n" + strings.Join(lines, "\n")
+}
+
+// Commands run around a sync to chain local automation
+type syncHooks struct {
+	PreSync  string
+	PostSync string
+	OnError  string
+}
+
+// Function to run a hook command through the shell, passing the run summary as JSON on stdin
+// and its main fields as SPOTIFY_SYNC_* environment variables
+func runHook(ctx context.Context, name, command string, summary runSummary) error {
+	if command == "" {
+		return nil
+	}
+
+	input, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	var playlists []string
+	for _, playlist := range summary.Playlists {
+		playlists = append(playlists, playlist.Name)
+	}
+
+	slog.Info("Running a hook", "hook", name)
+	// A hook that hangs is killed rather than block the run, or the daemon, forever
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"SPOTIFY_SYNC_HOOK="+name,
+		"SPOTIFY_SYNC_RUN_ID="+summary.RunID,
+		"SPOTIFY_SYNC_TENANT="+summary.Tenant,
+		"SPOTIFY_SYNC_PLAYLISTS="+strings.Join(playlists, ","),
+		fmt.Sprintf("SPOTIFY_SYNC_TRACKS_ADDED=%d", summary.TracksAdded),
+		"SPOTIFY_SYNC_ERROR="+summary.Error,
+		fmt.Sprintf("SPOTIFY_SYNC_PARTIAL=%t", summary.Partial),
+	)
+	return cmd.Run()
+}
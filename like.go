@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/eduardohitek/spotify-cli/spotify"
+)
+
+// Function to add tracks to the liked songs
+func runLike(ctx context.Context, args []string) {
+	runLibraryChange(ctx, "like", args, true)
+}
+
+// Function to remove tracks from the liked songs
+func runUnlike(ctx context.Context, args []string) {
+	runLibraryChange(ctx, "unlike", args, false)
+}
+
+func runLibraryChange(ctx context.Context, command string, args []string, like bool) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Printf("Usage: spotify-cli %s <track link, URI or ID>...\n", command)
+		os.Exit(2)
+	}
+	ids := make([]string, 0, flags.NArg())
+	for _, arg := range flags.Args() {
+		id, err := parseTrackRef(arg)
+		if err != nil {
+			fmt.Println("Error", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	if err := changeLibrary(ctx, ids, like); err != nil {
+		fmt.Printf("Error running %s: %v\n", command, explainError(err))
+	}
+}
+
+// Function to get the ID of a track given as an open.spotify.com link, a spotify:track: URI
+// or a bare ID
+func parseTrackRef(ref string) (string, error) {
+	id := ref
+	if rest, ok := strings.CutPrefix(ref, "spotify:track:"); ok {
+		id = rest
+	} else if strings.Contains(ref, "://") {
+		link, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("parsing %s: %w", ref, err)
+		}
+		// Links may carry a locale before the track, e.g. /intl-pt/track/<id>
+		_, rest, ok := strings.Cut(link.Path, "/track/")
+		if !ok {
+			return "", fmt.Errorf("%s is not a track link", ref)
+		}
+		id = rest
+	}
+	if id == "" || strings.ContainsAny(id, ":/?") {
+		return "", fmt.Errorf("%s is not a track", ref)
+	}
+	return id, nil
+}
+
+// Function to like or unlike the tracks with the given IDs, in batches of up to 50. With
+// --dry-run nothing is changed.
+func changeLibrary(ctx context.Context, ids []string, like bool) error {
+	action := "unlike"
+	if like {
+		action = "like"
+	}
+	if dryRun {
+		fmt.Printf("Dry run: would %s %d track(s).\n", action, len(ids))
+		return nil
+	}
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	if err := checkScopes(token, featureWriteLibrary); err != nil {
+		return err
+	}
+
+	client := apiClient(token.AccessToken)
+	for start := 0; start < len(ids); start += spotify.MaxTracksPerLibraryChange {
+		batch := ids[start:min(start+spotify.MaxTracksPerLibraryChange, len(ids))]
+		if like {
+			err = client.SaveTracks(ctx, batch)
+		} else {
+			err = client.RemoveSavedTracks(ctx, batch)
+		}
+		if err != nil {
+			return fmt.Errorf("changing %d liked track(s): %w", len(batch), err)
+		}
+	}
+	fmt.Printf("Done: %sd %d track(s).\n", action, len(ids))
+	return nil
+}
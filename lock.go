@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errLockHeld = errors.New("lock is held by another instance")
+
+// Longest interval between two refreshes of a held lock's mtime, shorter for short TTLs
+const maxLockRefreshInterval = time.Minute
+
+// A lock file on (possibly shared) storage, ensuring only one instance mutates playlists at a time
+type fileLock struct {
+	path string
+	// Random token written into the file, telling this instance's lock from a later one
+	token string
+	stop  chan struct{}
+	once  sync.Once
+}
+
+// Function to acquire the lock file, taking over locks older than ttl left behind by crashed
+// instances. The held lock's mtime is refreshed until it's released, so a long run keeps it.
+func acquireLock(path string, ttl time.Duration) (*fileLock, error) {
+	token := newCorrelationID()
+	for attempt := 0; attempt < 3; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			hostname, _ := os.Hostname()
+			fmt.Fprintf(file, "%s %d %s %s\n", hostname, os.Getpid(), time.Now().Format(time.RFC3339), token)
+			if err := file.Close(); err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			lock := &fileLock{path: path, token: token, stop: make(chan struct{})}
+			if ttl > 0 {
+				go lock.refresh(max(min(ttl/3, maxLockRefreshInterval), time.Second))
+			}
+			return lock, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if ttl <= 0 || time.Since(info.ModTime()) < ttl {
+			return nil, errLockHeld
+		}
+		if err := takeOverStaleLock(path, token, ttl); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errLockHeld
+}
+
+// Function to remove a stale lock. It's first renamed to a name of this instance's own, so only
+// one of several instances seeing it stale gets it, then checked again: another instance may
+// have replaced it with a fresh lock in between, which is put back.
+func takeOverStaleLock(path, token string, ttl time.Duration) error {
+	stale := path + ".stale-" + token
+	if err := os.Rename(path, stale); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	info, err := os.Stat(stale)
+	if err != nil {
+		return err
+	}
+	if time.Since(info.ModTime()) < ttl {
+		// Link rather than rename, not to clobber a lock created since
+		err := os.Link(stale, path)
+		os.Remove(stale)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+		return errLockHeld
+	}
+	slog.Warn("Removing a stale lock", "path", path, "locked_at", info.ModTime().Format(time.RFC3339))
+	return os.Remove(stale)
+}
+
+// Function to touch the lock file every interval while it's held
+func (l *fileLock) refresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if !l.owned() {
+				slog.Warn("The lock was taken over by another instance", "path", l.path)
+				return
+			}
+			now := time.Now()
+			if err := os.Chtimes(l.path, now, now); err != nil {
+				slog.Warn("Could not refresh the lock", "path", l.path, "error", err)
+			}
+		}
+	}
+}
+
+// Function to check that the lock file is still the one this instance wrote
+func (l *fileLock) owned() bool {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data))
+	return len(fields) > 0 && fields[len(fields)-1] == l.token
+}
+
+// Function to release the lock, leaving the file alone if another instance has taken it over
+func (l *fileLock) Release() error {
+	l.once.Do(func() { close(l.stop) })
+	if !l.owned() {
+		return nil
+	}
+	return os.Remove(l.path)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Level of the logs, set with --log-level
+var logLevel = new(slog.LevelVar)
+
+// Where the logs go; the daemon also copies them into the log file of each run
+var logOutput = &logWriter{w: os.Stderr}
+
+type logWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// Function to copy the logs into w as well, returning the function stopping it
+func (lw *logWriter) tee(w io.Writer) func() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	previous := lw.w
+	lw.w = io.MultiWriter(previous, w)
+	return func() {
+		lw.mu.Lock()
+		defer lw.mu.Unlock()
+		lw.w = previous
+	}
+}
+
+// Handler adding to every record the correlation ID of its run, the process ID outside of runs,
+// and in the multi-tenant mode the tenant synced
+type runHandler struct {
+	slog.Handler
+}
+
+func (h runHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(slog.String("run_id", correlationID(ctx)))
+	if t, ok := tenantFromContext(ctx); ok {
+		r.AddAttrs(slog.String("tenant", t.ID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h runHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return runHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h runHandler) WithGroup(name string) slog.Handler {
+	return runHandler{h.Handler.WithGroup(name)}
+}
+
+// Function to set up the logs from --log-level and --log-format, --verbose lowering the level to
+// debug and --quiet raising it to error. What else logs through the log package goes along.
+func setupLogging(level, format string, verbose, quiet bool) error {
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("parsing --log-level: %w", err)
+	}
+	switch {
+	case quiet:
+		logLevel.Set(slog.LevelError)
+	case verbose:
+		logLevel.Set(slog.LevelDebug)
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(logOutput, options)
+	case "json":
+		handler = slog.NewJSONHandler(logOutput, options)
+	default:
+		return fmt.Errorf("unknown --log-format %q: use text or json", format)
+	}
+	slog.SetDefault(slog.New(runHandler{handler}))
+	return nil
+}
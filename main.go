@@ -1,277 +1,534 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"html"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/eduardohitek/spotify-cli/spotify"
 	"github.com/joho/godotenv"
 )
 
 // Spotify API base URLs
 const (
-	baseAPIURL      = "https://api.spotify.com/v1"
+	baseAPIURL      = spotify.DefaultBaseURL
 	refreshTokenURL = "https://accounts.spotify.com/api/token"
 )
 
+// Shared client for every call to the Spotify accounts and Web API
+var httpClient = &http.Client{}
+
+// Transport under the Spotify app pool, replaced by the VCR transport when recording or replaying
+var baseTransport = http.DefaultTransport
+
 // Struct for response data
 type AccessTokenResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
 }
 
-type LikedSongsSearchResponse struct {
-	Total int         `json:"total"`
-	Items []LikedSong `json:"items"`
-}
+type UserProfile = spotify.User
 
-type LikedSong struct {
-	AddedAt time.Time `json:"added_at"`
-	Track   Track     `json:"track"`
-}
+// Profiles already fetched, keyed by access token, so /me is requested once per token
+var (
+	profilesMu sync.Mutex
+	profiles   = map[string]UserProfile{}
+)
 
-type Track struct {
-	ID      string   `json:"id"`
-	Name    string   `json:"name"`
-	Artists []Artist `json:"artists"`
-}
+type LikedSongsSearchResponse = spotify.Page[LikedSong]
+
+type LikedSong = spotify.SavedTrack
+
+type Track = spotify.Track
+
+type Artist = spotify.Artist
 
-type Artist struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+// Function to get a client calling the Web API with the given access token
+func apiClient(accessToken string) *spotify.Client {
+	client := spotify.NewClient(spotify.StaticToken(accessToken), runClient(accessToken))
+	client.BaseURL = baseAPIURL
+	return client
 }
 
-// Function to get a new access token using the refresh token
-func getAccessToken(clientID, clientSecret, refreshToken string) (string, error) {
-	req, err := http.NewRequest("POST", refreshTokenURL, strings.NewReader(fmt.Sprintf("grant_type=refresh_token&refresh_token=%s", refreshToken)))
+// Function to get a new access token using the refresh token. Apps without a client secret
+// were authorized with PKCE and only name their client ID.
+func getAccessToken(ctx context.Context, clientID, clientSecret, refreshToken string) (AccessTokenResponse, error) {
+	var tokenResponse AccessTokenResponse
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+	req, err := newTokenRequest(ctx, clientID, clientSecret, form)
 	if err != nil {
-		return "", err
+		return tokenResponse, err
 	}
-	req.SetBasicAuth(clientID, clientSecret)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return tokenResponse, err
 	}
 	defer resp.Body.Close()
 
-	var tokenResponse AccessTokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-		return "", err
+		return tokenResponse, err
 	}
 
-	return tokenResponse.AccessToken, nil
+	return tokenResponse, nil
 }
 
-// Function to get liked songs
-func getLikedSongs(accessToken string) ([]Track, error) {
-	var response LikedSongsSearchResponse
-	req, _ := http.NewRequest("GET", baseAPIURL+"/me/tracks?limit=50", nil)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+// Function to get the profile of the authenticated user, cached for the lifetime of the token
+func getCurrentUser(ctx context.Context, accessToken string) (UserProfile, error) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	if profile, ok := profiles[accessToken]; ok {
+		return profile, nil
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	profile, err := apiClient(accessToken).CurrentUser(ctx)
 	if err != nil {
-		return nil, err
+		return profile, err
 	}
-	defer resp.Body.Close()
+	profiles[accessToken] = profile
+	return profile, nil
+}
 
-	body, _ := io.ReadAll(resp.Body)
-	err = json.Unmarshal(body, &response)
+// Function to get liked songs
+func getLikedSongs(ctx context.Context, accessToken string, clock Clock) ([]Track, error) {
+	response, err := fetchLikedSongs(ctx, accessToken, periodStart(clock.Now()))
 	if err != nil {
 		return nil, err
 	}
-	likedTrackforCurrentMonth := filterLikedSongsForCurrentMonth(response)
+	likedTrackforCurrentMonth := filterLikedSongsForCurrentMonth(response, clock)
 
-	log.Printf("Were found %d liked song(s) for this month", len(likedTrackforCurrentMonth))
+	slog.InfoContext(ctx, "Found the liked songs of the period", "songs", len(likedTrackforCurrentMonth), "period", periodNoun())
 
 	return likedTrackforCurrentMonth, nil
 }
 
-func filterLikedSongsForCurrentMonth(likedSongs LikedSongsSearchResponse) []Track {
-	var likedSongsForCurrentMonth []Track
+// Function to fetch the liked songs added since the given time, following the pages newest
+// first until they are exhausted or reach songs liked before it
+func fetchLikedSongs(ctx context.Context, accessToken string, since time.Time) (LikedSongsSearchResponse, error) {
+	var response LikedSongsSearchResponse
+	err := eachLikedSongsPage(ctx, accessToken, since, func(page LikedSongsSearchResponse) error {
+		response.Total = page.Total
+		response.Items = append(response.Items, page.Items...)
+		return nil
+	})
+	return response, err
+}
+
+// Function to hand the pages of liked songs to fn one at a time, newest first, until they are
+// exhausted or reach songs liked before since, so huge libraries needn't be held in memory. The
+// first page is handed over even when empty.
+func eachLikedSongsPage(ctx context.Context, accessToken string, since time.Time, fn func(page LikedSongsSearchResponse) error) error {
+	client := apiClient(accessToken)
+	for offset := 0; ; {
+		var page LikedSongsSearchResponse
+		err := fetchPage("/me/tracks", func(limit int) (err error) {
+			page, err = client.LikedSongs(ctx, spotify.PageOptions{Limit: limit, Offset: offset})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		offset += len(page.Items)
+		if page.Next == "" || len(page.Items) == 0 || page.Items[len(page.Items)-1].AddedAt.Before(since) {
+			return nil
+		}
+	}
+}
+
+// Function to cheaply read the size of the library and the newest like, fetching a single item
+func probeLikedSongs(ctx context.Context, accessToken string) (int, time.Time, error) {
+	response, err := apiClient(accessToken).LikedSongs(ctx, spotify.PageOptions{Limit: 1})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var newest time.Time
+	if len(response.Items) > 0 {
+		newest = response.Items[0].AddedAt
+	}
+	return response.Total, newest, nil
+}
+
+// Function to keep the songs liked in the clock's period (its month by default) up to its
+// current time, the period of each like being taken in the clock's time zone
+func filterLikedSongsForCurrentMonth(likedSongs LikedSongsSearchResponse, clock Clock) []Track {
+	now := clock.Now()
+	likedSongsForCurrentMonth := make([]Track, 0, len(likedSongs.Items))
 	for _, song := range likedSongs.Items {
-		if song.AddedAt.Month() == time.Now().Month() {
+		if periodStart(song.AddedAt.In(now.Location())).Equal(periodStart(now)) && !song.AddedAt.After(now) {
 			likedSongsForCurrentMonth = append(likedSongsForCurrentMonth, song.Track)
 		}
 	}
 	return likedSongsForCurrentMonth
 }
 
-// Function to create a playlist
-func createPlaylist(accessToken string, playlistName string) (string, error) {
-	userID := "eduardohitek" // Replace with your Spotify User ID
-	payload := map[string]string{
-		"name":        playlistName,
-		"description": "Monthly Playlist",
-		"public":      "false",
-	}
-	body, _ := json.Marshal(payload)
+// Go time layout of the monthly playlist names, set with --name-format
+var playlistNameFormat = "Jan'06"
 
-	req, _ := http.NewRequest("POST", baseAPIURL+"/users/"+userID+"/playlists", bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
+// Playlist creation settings, set with --public and --user-id
+var (
+	playlistsPublic bool
+	userIDOverride  string
+)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Function to create a playlist, for the authenticated user unless --user-id names another one
+func createPlaylist(ctx context.Context, accessToken, playlistName, description string) (string, error) {
+	userID := userIDOverride
+	if userID == "" {
+		profile, err := getCurrentUser(ctx, accessToken)
+		if err != nil {
+			return "", fmt.Errorf("getting current user: %w", err)
+		}
+		userID = profile.ID
+	}
+	playlist, err := apiClient(accessToken).CreatePlaylist(ctx, userID, spotify.NewPlaylist{
+		Name:        sanitizePlaylistName(playlistName),
+		Description: sanitizePlaylistDescription(description),
+		Public:      playlistsPublic,
+	})
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	return playlist.ID, nil
+}
+
+// Function to search the user's playlists for an existing one, following the pages until it's
+// found or the list ends
+func searchPlaylist(ctx context.Context, accessToken, playlistName string) (spotify.Playlist, bool, error) {
+	client := apiClient(accessToken)
+	for offset := 0; ; {
+		var page spotify.Page[spotify.Playlist]
+		err := fetchPage("/me/playlists", func(limit int) (err error) {
+			page, err = client.Playlists(ctx, spotify.PageOptions{Limit: limit, Offset: offset})
+			return err
+		})
+		if err != nil {
+			return spotify.Playlist{}, false, err
+		}
+		for _, playlist := range page.Items {
+			if playlistNamesMatch(playlist.Name, playlistName) {
+				return playlist, true, nil
+			}
+		}
+		offset += len(page.Items)
+		if page.Next == "" || len(page.Items) == 0 {
+			return spotify.Playlist{}, false, nil
+		}
+	}
+}
 
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
-	playlistID := result["id"].(string)
+// Function to get the current description of a playlist
+func getPlaylistDescription(ctx context.Context, accessToken, playlistID string) (string, error) {
+	playlist, err := apiClient(accessToken).Playlist(ctx, playlistID, "description")
+	return html.UnescapeString(playlist.Description), err
+}
 
-	return playlistID, nil
+// Function to rename a playlist
+func updatePlaylistName(ctx context.Context, accessToken, playlistID, name string) error {
+	name = sanitizePlaylistName(name)
+	return apiClient(accessToken).ChangePlaylistDetails(ctx, playlistID, spotify.PlaylistDetails{Name: &name})
 }
 
-// Function to search for an existing playlist
-func searchPlaylist(accessToken, playlistName string) (string, error) {
-	req, _ := http.NewRequest("GET", baseAPIURL+"/me/playlists?limit=50", nil)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+// Function to change the description of a playlist
+func updatePlaylistDescription(ctx context.Context, accessToken, playlistID, description string) error {
+	description = sanitizePlaylistDescription(description)
+	return apiClient(accessToken).ChangePlaylistDetails(ctx, playlistID, spotify.PlaylistDetails{Description: &description})
+}
+
+// Function to check whether a playlist still exists, given its ID
+func playlistExists(ctx context.Context, accessToken, playlistID string) (bool, error) {
+	_, err := apiClient(accessToken).Playlist(ctx, playlistID, "id")
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Function to tell whether the API answered that what was asked for doesn't exist
+func isNotFound(err error) bool {
+	var apiErr *spotify.Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// Function to add to API errors what the user can do about them
+func explainError(err error) error {
+	var apiErr *spotify.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch apiErr.Status {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("%w; Spotify rejected the access token, check the refresh token in %s or authorize the app again", err, envFile)
+	case http.StatusForbidden:
+		return fmt.Errorf("%w; the token may be missing a scope (see the status command), or the account isn't allowed to use the app while it's in development mode", err)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w; Spotify is rate limiting the app, try again later or configure more apps", err)
+	}
+	return err
+}
+
+// Function to search for a playlist by name, creating it when it doesn't exist
+func findOrCreatePlaylist(ctx context.Context, accessToken, playlistName, description string) (string, error) {
+	playlist, found, err := searchPlaylist(ctx, accessToken, playlistName)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	if found {
+		return playlist.ID, nil
+	}
+	return createPlaylist(ctx, accessToken, playlistName, description)
+}
+
+// Most items Spotify accepts in a single add-items-to-playlist request
+const maxTracksPerRequest = 100
 
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
+// Function to add a song to a playlist, returning the tracks that were actually added.
+// The tracks are appended, or prepended to the top of the playlist keeping their order.
+// The playlist is read once and the ones not in it yet are sent in batches of up to 100.
+func addSongToPlaylist(ctx context.Context, accessToken, playlistID string, tracks []Track, prepend bool) ([]Track, error) {
+	missing, err := missingFromPlaylist(ctx, accessToken, playlistID, tracks)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Checked the tracks already in the playlist", "playlist_id", playlistID, "tracks", len(tracks), "already_added", len(tracks)-len(missing))
 
-	playlists := result["items"].([]interface{})
-	for _, playlist := range playlists {
-		pl := playlist.(map[string]interface{})
-		if pl["name"].(string) == playlistName {
-			return pl["id"].(string), nil
+	var batches [][]Track
+	for start := 0; start < len(missing); start += maxTracksPerRequest {
+		batches = append(batches, missing[start:min(start+maxTracksPerRequest, len(missing))])
+	}
+	var opts spotify.AddTracksOptions
+	if prepend {
+		// Each batch goes to the top, so add them last to first
+		opts.Position = new(int)
+		for i, j := 0, len(batches)-1; i < j; i, j = i+1, j-1 {
+			batches[i], batches[j] = batches[j], batches[i]
 		}
 	}
-	return "", nil
+
+	added := make([]Track, 0, len(missing))
+	for _, batch := range batches {
+		uris := make([]string, 0, len(batch))
+		for _, track := range batch {
+			slog.InfoContext(ctx, "Adding the track to the playlist", "playlist_id", playlistID, "track_id", track.ID, "track", track.Name, "artists", artistNames(track))
+			uris = append(uris, trackURI(track))
+		}
+		if _, err := apiClient(accessToken).AddTracks(ctx, playlistID, uris, opts); err != nil {
+			return added, fmt.Errorf("adding %d track(s): %w", len(batch), err)
+		}
+		added = append(added, batch...)
+	}
+	return added, nil
 }
 
-// Function to add a song to a playlist
-func addSongToPlaylist(accessToken, playlistID string, tracks []Track) error {
-	for _, track := range tracks {
-		log.Printf("Checking if the track %s by %s is already in the playlist.\n", track.Name, track.Artists[0].Name)
-		exists, err := checkSongAlreadyInPlaylist(accessToken, playlistID, track.ID)
-		if err != nil {
-			return err
+// Function to remove every occurrence of the given tracks from a playlist, in batches of up to 100
+func removeFromPlaylist(ctx context.Context, accessToken, playlistID string, tracks []Track) error {
+	for start := 0; start < len(tracks); start += spotify.MaxTracksPerRemoval {
+		batch := tracks[start:min(start+spotify.MaxTracksPerRemoval, len(tracks))]
+		remove := make([]spotify.TrackToRemove, 0, len(batch))
+		for _, track := range batch {
+			slog.InfoContext(ctx, "Removing the track from the playlist", "playlist_id", playlistID, "track_id", track.ID, "track", track.Name, "artists", artistNames(track))
+			remove = append(remove, spotify.TrackToRemove{URI: trackURI(track)})
 		}
-		if !exists {
-			log.Printf("Adding the track %s by %s to the playlist.\n", track.Name, track.Artists[0].Name)
-
-			req, _ := http.NewRequest("POST", baseAPIURL+"/playlists/"+playlistID+"/tracks?uris=spotify:track:"+track.ID, nil)
-			req.Header.Set("Authorization", "Bearer "+accessToken)
-			req.Header.Set("Content-Type", "application/json")
-
-			client := &http.Client{}
-			_, err := client.Do(req)
-			if err != nil {
-				log.Println(err)
-				return err
-			}
+		if _, err := apiClient(accessToken).RemoveTracks(ctx, playlistID, remove, ""); err != nil {
+			return fmt.Errorf("removing %d track(s): %w", len(batch), err)
 		}
 	}
 	return nil
 }
 
-func checkSongAlreadyInPlaylist(accessToken, playListID, trackID string) (bool, error) {
+// Function to unfollow a playlist, which is how Spotify deletes playlists owned by the user
+func unfollowPlaylist(ctx context.Context, accessToken, playlistID string) error {
+	return apiClient(accessToken).UnfollowPlaylist(ctx, playlistID)
+}
 
-	var response struct {
-		Items []struct {
-			Track struct {
-				ID string `json:"id"`
-			} `json:"track"`
-		} `json:"items"`
-	}
+// Fields of the playlist items the duplicate checks need, so reading large playlists stays light;
+// next is kept to follow the pages
+const contentsFields = "items(track(id,external_ids(isrc))),next,total"
 
-	req, _ := http.NewRequest("GET", baseAPIURL+"/playlists/"+playListID+"/tracks?limit=100", nil)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
+// Fields of the playlist items read to prune them, with the names for the log
+const pruneFields = "items(added_by(id),track(id,name,artists(name))),next,total"
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Function to get the items of a playlist, following its pages, with who added each track and when.
+// fields, when not empty, limits the items to the given fields and must include next. When they
+// include total, the pages after the first are fetched by up to --page-workers requests at once.
+func getPlaylistItems(ctx context.Context, accessToken, playListID, fields string) ([]spotify.PlaylistTrack, error) {
+	first, err := playlistItemsPage(ctx, accessToken, playListID, fields, 0, 0)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	items := first.Items
+	if first.Next == "" || len(first.Items) == 0 {
+		return items, nil
+	}
+	if first.Total == 0 || pageWorkers <= 1 {
+		rest, err := playlistItemsFrom(ctx, accessToken, playListID, fields, len(items), 0)
+		return append(items, rest...), err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return false, err
+	// Split the rest of the playlist into ranges of a page each
+	size := len(first.Items)
+	ranges := (first.Total - size + size - 1) / size
+	pages := make([][]spotify.PlaylistTrack, ranges)
+	err = runConcurrently(pageWorkers, ranges, func(i int) (err error) {
+		start := size + i*size
+		pages[i], err = playlistItemsFrom(ctx, accessToken, playListID, fields, start, min(start+size, first.Total))
+		return err
+	})
+	for _, page := range pages {
+		items = append(items, page...)
 	}
+	return items, err
+}
 
-	for _, item := range response.Items {
-		if item.Track.ID == trackID {
-			return true, nil
+// Function to get a page of the items of a playlist, of at most limit items when limit isn't 0
+func playlistItemsPage(ctx context.Context, accessToken, playListID, fields string, offset, limit int) (spotify.Page[spotify.PlaylistTrack], error) {
+	var page spotify.Page[spotify.PlaylistTrack]
+	err := fetchPage("/playlists/{id}/tracks", func(size int) (err error) {
+		if limit > 0 {
+			size = min(size, limit)
 		}
-	}
-	return false, nil
+		page, err = apiClient(accessToken).PlaylistTracks(ctx, playListID, spotify.PageOptions{Limit: size, Offset: offset, Fields: fields})
+		return err
+	})
+	return page, err
+}
 
+// Function to get the items of a playlist from start up to end, following the pages; an end of 0
+// reads to the end of the playlist
+func playlistItemsFrom(ctx context.Context, accessToken, playListID, fields string, start, end int) ([]spotify.PlaylistTrack, error) {
+	var items []spotify.PlaylistTrack
+	for offset := start; end == 0 || offset < end; {
+		limit := 0
+		if end > 0 {
+			limit = end - offset
+		}
+		page, err := playlistItemsPage(ctx, accessToken, playListID, fields, offset, limit)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, page.Items...)
+		offset += len(page.Items)
+		if page.Next == "" || len(page.Items) == 0 {
+			break
+		}
+	}
+	return items, nil
 }
 
-func main() {
-	loadEnvFile()
+// Function to hand the pages of a playlist's items to fn one at a time, in order, so huge
+// playlists needn't be held in memory. The first page is handed over even when empty.
+func eachPlaylistItemsPage(ctx context.Context, accessToken, playListID, fields string, fn func(items []spotify.PlaylistTrack) error) error {
+	for offset := 0; ; {
+		page, err := playlistItemsPage(ctx, accessToken, playListID, fields, offset, 0)
+		if err != nil {
+			return err
+		}
+		if err := fn(page.Items); err != nil {
+			return err
+		}
+		offset += len(page.Items)
+		if page.Next == "" || len(page.Items) == 0 {
+			return nil
+		}
+	}
+}
 
-	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
-	clientSecret := os.Getenv("SPOTIFY_CLIENT_SECRET")
-	refreshToken := os.Getenv("SPOTIFY_REFRESH_TOKEN")
-	// Get the current month and year for playlist naming
-	currentTime := time.Now()
-	playlistName := fmt.Sprintf("%s'%d", currentTime.Format("Jan"), currentTime.Year()%100)
+// Function to get the tracks in a playlist
+func getPlaylistTracks(ctx context.Context, accessToken, playListID string) ([]Track, error) {
+	return playlistTracks(getPlaylistItems(ctx, accessToken, playListID, ""))
+}
 
-	// Get access token
-	accessToken, err := getAccessToken(clientID, clientSecret, refreshToken)
+func playlistTracks(items []spotify.PlaylistTrack, err error) ([]Track, error) {
 	if err != nil {
-		fmt.Println("Error getting access token:", err)
-		return
+		return nil, err
+	}
+
+	tracks := make([]Track, 0, len(items))
+	for _, item := range items {
+		tracks = append(tracks, item.Track)
 	}
+	return tracks, nil
+}
+
+// Tracks in a playlist, by ID and by ISRC, so the same recording released under another ID
+// counts as present
+type playlistContents struct {
+	ids   map[string]bool
+	isrcs map[string]bool
+}
+
+func (c playlistContents) contains(track Track) bool {
+	return c.ids[track.ID] || (track.ExternalIDs.ISRC != "" && c.isrcs[track.ExternalIDs.ISRC])
+}
+
+func (c playlistContents) add(track Track) {
+	c.ids[track.ID] = true
+	if track.ExternalIDs.ISRC != "" {
+		c.isrcs[track.ExternalIDs.ISRC] = true
+	}
+}
 
-	// Get the latest liked song
-	likedSongs, err := getLikedSongs(accessToken)
+// Function to read the contents of a playlist once, to diff tracks against it locally
+func getPlaylistContents(ctx context.Context, accessToken, playListID string) (playlistContents, error) {
+	contents := playlistContents{ids: map[string]bool{}, isrcs: map[string]bool{}}
+	tracks, err := playlistTracks(getPlaylistItems(ctx, accessToken, playListID, contentsFields))
 	if err != nil {
-		fmt.Println("Error getting liked songs:", err)
-		return
+		return contents, err
+	}
+	for _, track := range tracks {
+		contents.add(track)
 	}
+	return contents, nil
+}
 
-	// Check if the playlist exists
-	playlistID, err := searchPlaylist(accessToken, playlistName)
+// Function to list the tracks that are not in the playlist, each one once
+func missingFromPlaylist(ctx context.Context, accessToken, playlistID string, tracks []Track) ([]Track, error) {
+	contents, err := getPlaylistContents(ctx, accessToken, playlistID)
 	if err != nil {
-		fmt.Println("Error searching playlist:", err)
-		return
+		return nil, err
 	}
 
-	// If playlist doesn't exist, create it
-	if playlistID == "" {
-		playlistID, err = createPlaylist(accessToken, playlistName)
-		if err != nil {
-			fmt.Println("Error creating playlist:", err)
-			return
+	var missing []Track
+	for _, track := range tracks {
+		if !contents.contains(track) {
+			contents.add(track)
+			missing = append(missing, track)
 		}
 	}
+	return missing, nil
+}
 
-	// Add the liked song to the playlist
-	err = addSongToPlaylist(accessToken, playlistID, likedSongs)
-	if err != nil {
-		fmt.Println("Error adding song to playlist:", err)
-		return
-	}
+func main() {
+	runCLI(os.Args[1:])
+}
 
-	fmt.Println("Song added to playlist:", playlistName)
+// Function to record or replay the API traffic when SPOTIFY_VCR_MODE is set
+func setupVCR() {
+	if mode := os.Getenv("SPOTIFY_VCR_MODE"); mode != "" {
+		vcr, err := newVCRTransport(mode, os.Getenv("SPOTIFY_VCR_CASSETTE"), baseTransport)
+		if err != nil {
+			fmt.Println("Error setting up the VCR:", err)
+			os.Exit(1)
+		}
+		baseTransport = vcr
+		httpClient.Transport = vcr
+	}
 }
 
 func loadEnvFile() {
-	file, err := os.Stat(".env.local")
+	_, err := os.Stat(envFile)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			panic(err)
@@ -280,7 +537,7 @@ func loadEnvFile() {
 		return
 	}
 
-	if err := godotenv.Load(file.Name()); err != nil {
+	if err := godotenv.Load(envFile); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Matrix room used both as a notifier and as the room the bot listens to
+type matrixClient struct {
+	homeserver string
+	token      string
+	roomID     string
+}
+
+var matrixTxnID atomic.Int64
+
+// Function to read the Matrix settings: SPOTIFY_NOTIFY_MATRIX_HOMESERVER, _TOKEN and _ROOM
+func matrixFromEnv() (matrixClient, bool) {
+	m := matrixClient{
+		homeserver: strings.TrimSuffix(os.Getenv("SPOTIFY_NOTIFY_MATRIX_HOMESERVER"), "/"),
+		token:      os.Getenv("SPOTIFY_NOTIFY_MATRIX_TOKEN"),
+		roomID:     os.Getenv("SPOTIFY_NOTIFY_MATRIX_ROOM"),
+	}
+	return m, m.homeserver != "" && m.token != "" && m.roomID != ""
+}
+
+func (matrixClient) Name() string {
+	return "matrix"
+}
+
+func (m matrixClient) Notify(ctx context.Context, n notification) error {
+	text := n.Title + "\n" + n.Message
+	if n.URL != "" {
+		text += "\n" + n.URL
+	}
+	return m.send(ctx, text)
+}
+
+func (m matrixClient) authHeader() http.Header {
+	return http.Header{"Authorization": {"Bearer " + m.token}}
+}
+
+// Function to send a text message to the room
+func (m matrixClient) send(ctx context.Context, text string) error {
+	txnID := fmt.Sprintf("spotify-like-songs-%d-%d", time.Now().UnixNano(), matrixTxnID.Add(1))
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", m.homeserver, url.PathEscape(m.roomID), txnID)
+	return sendJSON(ctx, "PUT", endpoint, map[string]string{"msgtype": "m.text", "body": text}, m.authHeader())
+}
+
+func (m matrixClient) get(ctx context.Context, path string, query url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", m.homeserver+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header = m.authHeader()
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("matrix %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Part of the /sync response the bot cares about
+type matrixSyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []struct {
+					Type    string `json:"type"`
+					Sender  string `json:"sender"`
+					Content struct {
+						Body string `json:"body"`
+					} `json:"content"`
+				} `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// Function to run a Matrix bot answering !sync in the configured room
+func runMatrixBot(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("matrix-bot", flag.ExitOnError)
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	m, ok := matrixFromEnv()
+	if !ok {
+		fmt.Println("Error: SPOTIFY_NOTIFY_MATRIX_HOMESERVER, SPOTIFY_NOTIFY_MATRIX_TOKEN and SPOTIFY_NOTIFY_MATRIX_ROOM are required")
+		return
+	}
+
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := m.get(ctx, "/_matrix/client/v3/account/whoami", url.Values{}, &whoami); err != nil {
+		fmt.Println("Error connecting to Matrix:", err)
+		return
+	}
+
+	filter := fmt.Sprintf(`{"room":{"rooms":[%q],"timeline":{"limit":20}}}`, m.roomID)
+	since := ""
+	slog.Info("Listening for !sync in Matrix", "room", m.roomID, "user", whoami.UserID)
+	for ctx.Err() == nil {
+		// Long-polled, within the request timeout
+		query := url.Values{"timeout": {"20000"}, "filter": {filter}}
+		if since != "" {
+			query.Set("since", since)
+		}
+
+		var response matrixSyncResponse
+		if err := m.get(ctx, "/_matrix/client/v3/sync", query, &response); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Warn("Matrix sync failed", "error", err)
+			select {
+			case <-time.After(10 * time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		// The first batch is the room history, which must not trigger syncs
+		initial := since == ""
+		since = response.NextBatch
+		if initial {
+			continue
+		}
+
+		for _, event := range response.Rooms.Join[m.roomID].Timeline.Events {
+			if event.Type != "m.room.message" || event.Sender == whoami.UserID || strings.TrimSpace(event.Content.Body) != "!sync" {
+				continue
+			}
+			slog.Info("Asked for a sync in Matrix", "sender", event.Sender)
+			m.send(ctx, "Sync started…")
+			summary, err := runSyncWithHooks(ctx, opts)
+			if err := m.send(ctx, syncOutcomeText(summary, err)); err != nil {
+				slog.Warn("Could not answer in Matrix", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Results of the syncs counted by the metrics
+var syncResults = []string{"success", "partial", "failure"}
+
+// Counters of one tenant, or of the single user outside the multi-tenant mode
+type tenantMetrics struct {
+	// Finished syncs by result, partial ones having stopped at --max-api-calls
+	syncRuns map[string]int64
+	// Tracks added by the syncs
+	tracksAdded int64
+	// Attempts at Spotify API requests, each retry counting
+	apiRequests int64
+	// Attempts that failed or got an error status, rate limits apart
+	apiErrors int64
+	// Attempts rate limited by Spotify
+	rateLimited int64
+	// Unix time of the end of the last sync that didn't fail, 0 before the first one
+	lastSuccessfulSync int64
+}
+
+// Counters of the process by tenant, "" outside the multi-tenant mode, served in the Prometheus
+// text format by the daemon's --metrics-addr and on /metrics of tenant serve
+var (
+	metricsMu       sync.Mutex
+	metricsByTenant = map[string]*tenantMetrics{}
+)
+
+// Function to update the counters of a tenant
+func countMetrics(tenantID string, update func(m *tenantMetrics)) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	m, ok := metricsByTenant[tenantID]
+	if !ok {
+		m = &tenantMetrics{syncRuns: map[string]int64{}}
+		metricsByTenant[tenantID] = m
+	}
+	update(m)
+}
+
+// Function to get the ID of the tenant a context syncs, "" outside the multi-tenant mode
+func metricsTenant(ctx context.Context) string {
+	t, _ := tenantFromContext(ctx)
+	return t.ID
+}
+
+// Function to count a finished sync
+func recordSyncMetrics(ctx context.Context, summary runSummary, err error) {
+	result := "success"
+	switch {
+	case err != nil:
+		result = "failure"
+	case summary.Partial:
+		result = "partial"
+	}
+	countMetrics(metricsTenant(ctx), func(m *tenantMetrics) {
+		m.syncRuns[result]++
+		m.tracksAdded += int64(summary.TracksAdded)
+		if err == nil {
+			m.lastSuccessfulSync = summary.FinishedAt.Unix()
+		}
+	})
+}
+
+// Function to take the time of the last successful sync of a tenant from its run history, so a
+// restart doesn't look like the syncs stopped
+func seedSyncMetrics(tenantID, historyPath string) error {
+	if historyPath == "" {
+		return nil
+	}
+	history, err := loadRunHistory(historyPath)
+	if err != nil {
+		return err
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Error == "" {
+			countMetrics(tenantID, func(m *tenantMetrics) { m.lastSuccessfulSync = history[i].FinishedAt.Unix() })
+			break
+		}
+	}
+	return nil
+}
+
+// Transport counting the attempts at Spotify API requests, their errors and rate limits, by the
+// tenant of the request's context
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if !isAPIRequest(req.URL) {
+		return resp, err
+	}
+	countMetrics(metricsTenant(req.Context()), func(m *tenantMetrics) {
+		m.apiRequests++
+		switch {
+		case err == nil && resp.StatusCode == http.StatusTooManyRequests:
+			m.rateLimited++
+		case err != nil || resp.StatusCode >= http.StatusBadRequest:
+			m.apiErrors++
+		}
+	})
+	return resp, err
+}
+
+// Function to serve the metrics in the Prometheus text format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metricsMu.Lock()
+	tenants := make([]string, 0, len(metricsByTenant))
+	snapshot := make(map[string]tenantMetrics, len(metricsByTenant))
+	for id, m := range metricsByTenant {
+		tenants = append(tenants, id)
+		copied := *m
+		copied.syncRuns = maps.Clone(m.syncRuns)
+		snapshot[id] = copied
+	}
+	metricsMu.Unlock()
+	sort.Strings(tenants)
+	// The single user's counters are there before the first sync too
+	if len(tenants) == 0 {
+		tenants = []string{""}
+	}
+
+	var b strings.Builder
+	metricHeader(&b, "spotify_cli_syncs_total", "counter", "Syncs finished, by tenant and result.")
+	for _, id := range tenants {
+		for _, result := range syncResults {
+			fmt.Fprintf(&b, "spotify_cli_syncs_total%s %d\n", metricLabels(id, "result", result), snapshot[id].syncRuns[result])
+		}
+	}
+	writeMetric(&b, snapshot, tenants, "spotify_cli_tracks_added_total", "counter", "Tracks added to the playlists.", func(m tenantMetrics) int64 { return m.tracksAdded })
+	writeMetric(&b, snapshot, tenants, "spotify_cli_api_requests_total", "counter", "Attempts at Spotify API requests, retries included.", func(m tenantMetrics) int64 { return m.apiRequests })
+	writeMetric(&b, snapshot, tenants, "spotify_cli_api_errors_total", "counter", "Spotify API requests that failed or got an error status, rate limits apart.", func(m tenantMetrics) int64 { return m.apiErrors })
+	writeMetric(&b, snapshot, tenants, "spotify_cli_rate_limited_total", "counter", "Spotify API requests rate limited by Spotify.", func(m tenantMetrics) int64 { return m.rateLimited })
+	writeMetric(&b, snapshot, tenants, "spotify_cli_last_successful_sync_timestamp_seconds", "gauge", "Unix time of the end of the last sync that didn't fail.", func(m tenantMetrics) int64 { return m.lastSuccessfulSync })
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, b.String())
+}
+
+// Function to render the labels of a sample, the tenant label left out outside the multi-tenant mode
+func metricLabels(tenantID string, pairs ...string) string {
+	var labels []string
+	if tenantID != "" {
+		labels = append(labels, fmt.Sprintf("tenant=%q", tenantID))
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func metricHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeMetric(b *strings.Builder, snapshot map[string]tenantMetrics, tenants []string, name, kind, help string, value func(m tenantMetrics) int64) {
+	metricHeader(b, name, kind, help)
+	for _, id := range tenants {
+		fmt.Fprintf(b, "%s%s %d\n", name, metricLabels(id), value(snapshot[id]))
+	}
+}
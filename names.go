@@ -0,0 +1,86 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Typographic characters the Spotify apps may substitute while typing a playlist name
+var typographicReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "‛", "'", "′", "'",
+	"“", "\"", "”", "\"", "‟", "\"", "″", "\"",
+	"–", "-", "—", "-",
+	"\u00a0", " ",
+)
+
+var nameFolder = cases.Fold()
+
+// HTML-like tags, which Spotify doesn't render in descriptions
+var markupTags = regexp.MustCompile(`<[^>]*>`)
+
+// Function to normalize a playlist name for matching: NFC normalization, straight quotes,
+// collapsed whitespace and case folding
+func normalizePlaylistName(name string) string {
+	name = norm.NFC.String(name)
+	name = typographicReplacer.Replace(name)
+	name = strings.Join(strings.Fields(name), " ")
+	return nameFolder.String(name)
+}
+
+// Function to tell whether two playlist names refer to the same playlist
+func playlistNamesMatch(a, b string) bool {
+	return normalizePlaylistName(a) == normalizePlaylistName(b)
+}
+
+// Length limits Spotify enforces on playlist details, in characters
+const (
+	maxPlaylistNameLength        = 100
+	maxPlaylistDescriptionLength = 300
+)
+
+// Function to make a playlist name acceptable to Spotify: no control characters and within the length limit
+func sanitizePlaylistName(name string) string {
+	return sanitizePlaylistText("name", name, maxPlaylistNameLength, false)
+}
+
+// Function to make a playlist description acceptable to Spotify: a single line without markup, within the length limit
+func sanitizePlaylistDescription(description string) string {
+	description = markupTags.ReplaceAllString(description, "")
+	return sanitizePlaylistText("description", description, maxPlaylistDescriptionLength, true)
+}
+
+func sanitizePlaylistText(field, text string, limit int, singleLine bool) string {
+	text = norm.NFC.String(text)
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, text)
+	if singleLine {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	text = strings.TrimSpace(text)
+
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+
+	// Don't leave half of an emoji sequence at the end
+	cut := limit
+	for cut > 0 && (runes[cut-1] == '\u200d' || unicode.Is(unicode.Variation_Selector, runes[cut]) || runes[cut] == '\u200d' || unicode.Is(unicode.Mn, runes[cut])) {
+		cut--
+	}
+	truncated := strings.TrimSpace(string(runes[:cut]))
+	slog.Warn("Truncated a playlist text longer than Spotify allows", "field", field, "text", text, "limit", limit, "truncated", truncated)
+	return truncated
+}
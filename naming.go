@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Naming template of the monthly playlists, set with --name-template. When empty the playlists
+// are named with --name-format.
+var playlistNameTemplate *template.Template
+
+// Language of the month names in the naming template, set with --name-locale
+var playlistNameLocale = "en"
+
+// Month names by language, January first
+var monthNames = map[string][12]string{
+	"en": {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	"pt": {"Janeiro", "Fevereiro", "Março", "Abril", "Maio", "Junho", "Julho", "Agosto", "Setembro", "Outubro", "Novembro", "Dezembro"},
+	"es": {"Enero", "Febrero", "Marzo", "Abril", "Mayo", "Junio", "Julio", "Agosto", "Septiembre", "Octubre", "Noviembre", "Diciembre"},
+	"fr": {"Janvier", "Février", "Mars", "Avril", "Mai", "Juin", "Juillet", "Août", "Septembre", "Octobre", "Novembre", "Décembre"},
+	"de": {"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+}
+
+// Variables of the naming template
+type playlistNameData struct {
+	// Month number, 1 to 12
+	Month int
+	// Full and three-letter month names in the --name-locale language
+	MonthName      string
+	ShortMonthName string
+	// Year, e.g. 2025, and its last two digits, e.g. 25
+	Year      int
+	ShortYear string
+	// ISO week number and quarter, 1 to 4, of the period's start
+	Week    int
+	Quarter int
+	// Number of songs the sync put in the playlist
+	Tracks int
+}
+
+// Function to parse the naming template and check it against the locale
+func parseNameTemplate(text, locale string) (*template.Template, error) {
+	if _, ok := monthNames[locale]; !ok {
+		return nil, fmt.Errorf("unknown --name-locale %q", locale)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	// Catch references to unknown variables now rather than during a sync
+	if err := tmpl.Execute(&strings.Builder{}, playlistNameData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Function to get the title of a period's playlist in Spotify: the naming template applied to the
+// period starting at t and the number of songs, or the period's name when there is no template
+func playlistTitle(t time.Time, tracks int) string {
+	if playlistNameTemplate == nil {
+		return periodPlaylistName(t)
+	}
+	title, err := renderPlaylistTitle(playlistNameTemplate, playlistNameLocale, t, tracks)
+	if err != nil {
+		return periodPlaylistName(t)
+	}
+	return title
+}
+
+// Function to apply a naming template to the period starting at t and its number of songs
+func renderPlaylistTitle(tmpl *template.Template, locale string, t time.Time, tracks int) (string, error) {
+	names := monthNames[locale]
+	month := names[t.Month()-1]
+	_, week := t.ISOWeek()
+	data := playlistNameData{
+		Week:           week,
+		Quarter:        (int(t.Month())-1)/3 + 1,
+		Month:          int(t.Month()),
+		MonthName:      month,
+		ShortMonthName: string([]rune(month)[:3]),
+		Year:           t.Year(),
+		ShortYear:      fmt.Sprintf("%02d", t.Year()%100),
+		Tracks:         tracks,
+	}
+	var title strings.Builder
+	err := tmpl.Execute(&title, data)
+	return title.String(), err
+}
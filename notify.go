@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A message delivered through the configured notifiers
+type notification struct {
+	Title   string
+	Message string
+	URL     string
+	Failure bool
+	// Summary marks periodic roundups, such as a finished month, as opposed to per-run outcomes
+	Summary bool
+	// Digest marks the weekly roundup of runs, only sent to weekly-digest notifiers
+	Digest bool
+}
+
+// How long a notification backend gets to answer
+const notifyTimeout = 30 * time.Second
+
+// A backend delivering notifications
+type notifier interface {
+	Name() string
+	Notify(ctx context.Context, n notification) error
+}
+
+// Function to build the notifiers configured through the environment
+func loadNotifiers() ([]notifier, error) {
+	var notifiers []notifier
+	if url := os.Getenv("SPOTIFY_NOTIFY_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, webhookNotifier{url: url})
+	}
+	if matrix, ok := matrixFromEnv(); ok {
+		notifiers = append(notifiers, matrix)
+	}
+	notifiers = append(notifiers, pushNotifiersFromEnv()...)
+
+	pushover, ok, err := pushoverFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		notifiers = append(notifiers, pushover)
+	}
+
+	for i, nt := range notifiers {
+		policy, err := notifyPolicyFromEnv(nt.Name())
+		if err != nil {
+			return nil, err
+		}
+		notifiers[i] = policyNotifier{notifier: nt, policy: policy}
+	}
+	return notifiers, nil
+}
+
+// When a notifier is sent a notification
+const (
+	notifyAlways       = "always"
+	notifyFailuresOnly = "failures-only"
+	notifyWeeklyDigest = "weekly-digest"
+)
+
+// Function to read SPOTIFY_NOTIFY_<NAME>_POLICY, defaulting to always
+func notifyPolicyFromEnv(name string) (string, error) {
+	key := "SPOTIFY_NOTIFY_" + strings.ToUpper(name) + "_POLICY"
+	switch policy := os.Getenv(key); policy {
+	case "":
+		return notifyAlways, nil
+	case notifyAlways, notifyFailuresOnly, notifyWeeklyDigest:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%s must be %s, %s or %s, got %q", key, notifyAlways, notifyFailuresOnly, notifyWeeklyDigest, policy)
+	}
+}
+
+// Notifier dropping the notifications its policy does not ask for. Summaries always go through;
+// failures-only adds failed runs and weekly-digest trades every per-run outcome for the digest
+type policyNotifier struct {
+	notifier
+	policy string
+}
+
+func (p policyNotifier) wants(n notification) bool {
+	switch {
+	case n.Digest:
+		return p.policy == notifyWeeklyDigest
+	case n.Summary || p.policy == notifyAlways:
+		return true
+	case p.policy == notifyFailuresOnly:
+		return n.Failure
+	default:
+		return false
+	}
+}
+
+func (p policyNotifier) Notify(ctx context.Context, n notification) error {
+	if !p.wants(n) {
+		return nil
+	}
+	return p.notifier.Notify(ctx, n)
+}
+
+// Function to deliver a notification through every notifier, logging the ones that fail
+func sendNotification(ctx context.Context, notifiers []notifier, n notification) {
+	for _, nt := range notifiers {
+		// A backend that doesn't answer doesn't hold up the run
+		ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		err := nt.Notify(ctx, n)
+		cancel()
+		if err != nil {
+			slog.Warn("Could not send the notification", "notifier", nt.Name(), "error", err)
+		}
+	}
+}
+
+// Function to POST a JSON payload to a notification backend
+func postJSON(ctx context.Context, url string, payload any, header http.Header) error {
+	return sendJSON(ctx, "POST", url, payload, header)
+}
+
+func sendJSON(ctx context.Context, method, url string, payload any, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sending to %s: %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// Notifier posting to an incoming webhook; the payload is understood by Slack, Mattermost and Discord
+type webhookNotifier struct {
+	url string
+}
+
+func (webhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w webhookNotifier) Notify(ctx context.Context, n notification) error {
+	text := n.Title + "\n" + n.Message
+	if n.URL != "" {
+		text += "\n" + n.URL
+	}
+	return postJSON(ctx, w.url, map[string]string{"text": text, "content": text}, nil)
+}
+
+// Function to describe the outcome of a sync run
+func syncNotification(summary runSummary, err error) notification {
+	n := notification{Title: "Liked songs synced", Message: syncOutcomeText(summary, err), Failure: err != nil}
+	if err != nil {
+		n.Title = "Liked songs sync failed"
+	}
+	if summary.Owner != "" {
+		n.Title = summary.Owner + "'s " + strings.ToLower(n.Title[:1]) + n.Title[1:]
+	}
+	if len(summary.Playlists) > 0 {
+		n.URL = summary.Playlists[len(summary.Playlists)-1].URL
+	}
+	return n
+}
+
+// Function to summarize a finished monthly playlist: track count, total duration and top artist
+func finalizationNotification(playlistName, playlistID string, tracks []Track) notification {
+	var total time.Duration
+	artistCounts := map[string]int{}
+	for _, track := range tracks {
+		total += track.Duration()
+		for _, artist := range track.Artists {
+			artistCounts[artist.Name]++
+		}
+	}
+
+	artists := make([]string, 0, len(artistCounts))
+	for artist := range artistCounts {
+		artists = append(artists, artist)
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		if artistCounts[artists[i]] != artistCounts[artists[j]] {
+			return artistCounts[artists[i]] > artistCounts[artists[j]]
+		}
+		return artists[i] < artists[j]
+	})
+
+	message := fmt.Sprintf("%d track(s), %s of listening.", len(tracks), formatListeningTime(total))
+	if len(artists) > 0 {
+		message += fmt.Sprintf(" Top artist: %s (%d track(s)).", artists[0], artistCounts[artists[0]])
+	}
+	return notification{
+		Title:   playlistName + " is complete",
+		Message: message,
+		URL:     playlistURL(playlistID),
+		Summary: true,
+	}
+}
+
+func formatListeningTime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, minutes)
+}
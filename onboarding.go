@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// Naming templates offered on the onboarding page; the empty one keeps the --name-format names
+var onboardingTemplates = []string{
+	"",
+	"{{.MonthName}} {{.Year}}",
+	"{{.ShortMonthName}} '{{.ShortYear}}",
+	"{{.Year}}-{{printf \"%02d\" .Month}}",
+	"Liked in {{.MonthName}} {{.Year}}",
+}
+
+// A naming choice of the onboarding page, with the name it gives this month's playlist
+type onboardingChoice struct {
+	Template string
+	Example  string
+	Selected bool
+}
+
+type onboardingPageData struct {
+	Title   string
+	Message string
+	// Set on the form page: where it posts, the invite it carries and the naming choices
+	Action  string
+	Invite  string
+	Choices []onboardingChoice
+	Custom  string
+}
+
+// Head shared by the pages of the tenant server, taking the page's Title
+const pageHead = `{{define "head"}}<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 3rem auto; padding: 0 1rem; color: #191414; }
+body.wide { max-width: 60rem; }
+label { display: block; margin: .4rem 0; }
+input[type=text] { width: 100%; padding: .4rem; box-sizing: border-box; }
+button { margin-top: 1.2rem; padding: .7rem 1.4rem; border: 0; border-radius: 2rem; background: #1db954; color: #fff; font-size: 1rem; cursor: pointer; }
+table button { margin: 0; padding: .3rem .8rem; font-size: .85rem; }
+button.danger { background: #b00020; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .4rem .6rem; border-bottom: 1px solid #ddd; vertical-align: top; }
+form.inline { display: inline; }
+.error { color: #b00020; }
+</style>
+</head>{{end}}`
+
+// Pages of the tenant server, each a template of its own
+var webPages = template.Must(template.New("pages").Parse(pageHead + onboardingPageTemplate + adminPageTemplates))
+
+const onboardingPageTemplate = `{{define "onboarding"}}{{template "head" .}}
+<body>
+<h1>{{.Title}}</h1>
+{{if .Message}}<p{{if .Action}} class="error"{{end}}>{{.Message}}</p>{{end}}
+{{if .Action}}
+<p>Your liked songs will be gathered into a private playlist for each month, kept up to date automatically.</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="invite" value="{{.Invite}}">
+<h2>How should the playlists be named?</h2>
+{{range .Choices}}<label><input type="radio" name="template" value="{{.Template}}"{{if .Selected}} checked{{end}}> {{.Example}}</label>
+{{end}}<label><input type="radio" name="template" value="custom"{{if .Custom}} checked{{end}}> Custom template:</label>
+<input type="text" name="custom" value="{{.Custom}}" placeholder="{{"{{"}}.MonthName{{"}}"}} {{"{{"}}.Year{{"}}"}} likes">
+<button type="submit">Connect Spotify</button>
+</form>
+{{end}}
+</body>
+</html>
+{{end}}`
+
+// Function to render one of the pages of the tenant server
+func renderPage(w http.ResponseWriter, status int, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := webPages.ExecuteTemplate(w, name, data); err != nil {
+		slog.Warn("Could not render the page", "page", name, "error", err)
+	}
+}
+
+func renderOnboardingPage(w http.ResponseWriter, status int, data onboardingPageData) {
+	renderPage(w, status, "onboarding", data)
+}
+
+// Function to find the tenant an onboarding request is for, when its invite is valid
+func invitedTenant(r *http.Request, invite string) (tenant, bool) {
+	t, err := loadTenant(r.PathValue("id"))
+	if err != nil || t.InviteHash == "" {
+		return t, false
+	}
+	return t, subtle.ConstantTimeCompare([]byte(inviteHash(invite)), []byte(t.InviteHash)) == 1
+}
+
+// Function to build the form of the onboarding page, the naming choices showing this month's name
+func onboardingForm(t tenant, invite, selected, custom, message string) onboardingPageData {
+	data := onboardingPageData{
+		Title:   "Monthly playlists of your liked songs",
+		Message: message,
+		Action:  "/onboard/" + t.ID,
+		Invite:  invite,
+		Custom:  custom,
+	}
+	if custom == "" && !slices.Contains(onboardingTemplates, selected) {
+		data.Custom = selected
+	}
+	now := nowInMonthZone()
+	for _, text := range onboardingTemplates {
+		example := now.Format(playlistNameFormat)
+		if text != "" {
+			tmpl, err := parseNameTemplate(text, playlistNameLocale)
+			if err != nil {
+				continue
+			}
+			if example, err = renderPlaylistTitle(tmpl, playlistNameLocale, monthStart(now), 0); err != nil {
+				continue
+			}
+		}
+		data.Choices = append(data.Choices, onboardingChoice{Template: text, Example: example, Selected: data.Custom == "" && text == selected})
+	}
+	return data
+}
+
+// Function to show the onboarding page of an invited tenant
+func (s *onboardingServer) handleOnboardPage(w http.ResponseWriter, r *http.Request) {
+	invite := r.URL.Query().Get("invite")
+	t, ok := invitedTenant(r, invite)
+	if !ok {
+		renderOnboardingPage(w, http.StatusNotFound, onboardingPageData{Title: "Invalid link", Message: "This onboarding link is invalid or was already used."})
+		return
+	}
+	renderOnboardingPage(w, http.StatusOK, onboardingForm(t, invite, t.NameTemplate, "", ""))
+}
+
+// Function to take the naming choice of the onboarding page and send the tenant to Spotify's
+// consent page
+func (s *onboardingServer) handleOnboard(w http.ResponseWriter, r *http.Request) {
+	invite := r.PostFormValue("invite")
+	t, ok := invitedTenant(r, invite)
+	if !ok {
+		renderOnboardingPage(w, http.StatusNotFound, onboardingPageData{Title: "Invalid link", Message: "This onboarding link is invalid or was already used."})
+		return
+	}
+
+	nameTemplate := r.PostFormValue("template")
+	if nameTemplate == "custom" {
+		nameTemplate = r.PostFormValue("custom")
+		if _, err := parseNameTemplate(nameTemplate, playlistNameLocale); err != nil || nameTemplate == "" {
+			message := "The custom template is empty."
+			if err != nil {
+				message = "The custom template doesn't work: " + err.Error()
+			}
+			renderOnboardingPage(w, http.StatusBadRequest, onboardingForm(t, invite, "", nameTemplate, message))
+			return
+		}
+	} else if _, err := parseNameTemplate(nameTemplate, playlistNameLocale); err != nil {
+		renderOnboardingPage(w, http.StatusBadRequest, onboardingForm(t, invite, "", "", "Pick one of the names."))
+		return
+	}
+
+	consentURL, err := s.startAuthorization(t, nameTemplate)
+	if err != nil {
+		slog.Error("Could not start the onboarding", "tenant", t.ID, "error", err)
+		renderOnboardingPage(w, http.StatusInternalServerError, onboardingPageData{Title: "Something went wrong", Message: "The authorization could not be started; try again."})
+		return
+	}
+	http.Redirect(w, r, consentURL, http.StatusSeeOther)
+}
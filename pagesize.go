@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+const (
+	minPageSize = 10
+	// Attempts at a page that times out, each at half the size of the previous one
+	maxPageAttempts = 3
+)
+
+// Page size of a paginated endpoint, starting at the maximum Spotify allows and
+// dropping to smaller pages when requests keep timing out
+type pageSize struct {
+	mu       sync.Mutex
+	endpoint string
+	max      int
+	current  int
+	logged   bool
+}
+
+// Page sizes of the paginated endpoints, keyed by endpoint
+var pageSizes = map[string]*pageSize{
+	"/me/tracks":             {endpoint: "/me/tracks", max: 50},
+	"/me/playlists":          {endpoint: "/me/playlists", max: 50},
+	"/playlists/{id}/tracks": {endpoint: "/playlists/{id}/tracks", max: 100},
+}
+
+func (p *pageSize) limit() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == 0 {
+		p.current = p.max
+	}
+	if !p.logged {
+		slog.Debug("Using a page size", "endpoint", p.endpoint, "page_size", p.current)
+		p.logged = true
+	}
+	return p.current
+}
+
+// Function to record a timed out page, halving the page size, returning false once it's
+// already the smallest
+func (p *pageSize) timedOut() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current <= minPageSize {
+		return false
+	}
+	p.current = max(p.current/2, minPageSize)
+	slog.Warn("Requests keep timing out; using smaller pages", "endpoint", p.endpoint, "page_size", p.current)
+	return true
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Function to fetch a page of a paginated endpoint with the effective page size. The transport
+// already retries a timed out request, so a page that still times out is fetched again at half
+// the size, a few times, the following pages keeping the smaller size.
+func fetchPage(endpoint string, fetch func(limit int) error) error {
+	size := pageSizes[endpoint]
+	var err error
+	for attempt := 0; attempt < maxPageAttempts; attempt++ {
+		if err = fetch(size.limit()); !isTimeout(err) {
+			return err
+		}
+		if !size.timedOut() {
+			break
+		}
+	}
+	return fmt.Errorf("%s timed out: %w", endpoint, err)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Granularity of the playlists: which liked songs go together and how the playlists are named
+const (
+	periodWeekly    = "weekly"
+	periodMonthly   = "monthly"
+	periodQuarterly = "quarterly"
+	periodYearly    = "yearly"
+)
+
+// Granularity of the playlists, set with --period
+var syncPeriod = periodMonthly
+
+// Function to check a --period value
+func parsePeriod(value string) (string, error) {
+	switch value {
+	case periodWeekly, periodMonthly, periodQuarterly, periodYearly:
+		return value, nil
+	}
+	return "", fmt.Errorf("must be %s, %s, %s or %s", periodWeekly, periodMonthly, periodQuarterly, periodYearly)
+}
+
+// Function to get the word for a period, e.g. week
+func periodNoun() string {
+	switch syncPeriod {
+	case periodWeekly:
+		return "week"
+	case periodQuarterly:
+		return "quarter"
+	case periodYearly:
+		return "year"
+	}
+	return "month"
+}
+
+// Function to get the start of the period holding t: the Monday of its ISO week, the first day
+// of its month, quarter or year
+func periodStart(t time.Time) time.Time {
+	switch syncPeriod {
+	case periodWeekly:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case periodQuarterly:
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, t.Location())
+	case periodYearly:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	return monthStart(t)
+}
+
+// Function to get the start of the period after the one holding t
+func nextPeriod(t time.Time) time.Time {
+	start := periodStart(t)
+	switch syncPeriod {
+	case periodWeekly:
+		return start.AddDate(0, 0, 7)
+	case periodQuarterly:
+		return start.AddDate(0, 3, 0)
+	case periodYearly:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// Function to get the start of the period before the one holding t
+func previousPeriod(t time.Time) time.Time {
+	return periodStart(periodStart(t).Add(-time.Nanosecond))
+}
+
+// Function to get the last instant of the period holding t
+func periodEnd(t time.Time) time.Time {
+	return nextPeriod(t).Add(-time.Nanosecond)
+}
+
+// Function to get the clock a period is synced as of: its end, or now while it's still running
+func periodClock(start time.Time) Clock {
+	if now := nowInMonthZone(); !periodEnd(start).Before(now) {
+		return fixedClock{t: now}
+	}
+	return fixedClock{t: periodEnd(start)}
+}
+
+// Function to parse a period argument, a month (YYYY-MM) or a day (YYYY-MM-DD) in the period,
+// into the start of the period holding it
+func parsePeriodArg(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", value, monthZone)
+	if err != nil {
+		if t, err = time.ParseInLocation("2006-01", value, monthZone); err != nil {
+			return time.Time{}, fmt.Errorf("expected a month like 2025-03 or a day like 2025-03-17")
+		}
+	}
+	return periodStart(t), nil
+}
+
+// Function to name the playlist of the period holding t: the monthly name given by
+// --name-format, or e.g. Week 12 '25, Q1 '25 and 2025. The state keeps the playlist under
+// this name; its title in Spotify comes from --name-template when given.
+func periodPlaylistName(t time.Time) string {
+	switch syncPeriod {
+	case periodWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("Week %d '%02d", week, year%100)
+	case periodQuarterly:
+		return fmt.Sprintf("Q%d '%02d", (int(t.Month())-1)/3+1, t.Year()%100)
+	case periodYearly:
+		return fmt.Sprintf("%d", t.Year())
+	}
+	return t.Format(playlistNameFormat)
+}
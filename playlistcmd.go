@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/eduardohitek/spotify-cli/spotify"
+)
+
+// Function to manage playlists
+func runPlaylist(ctx context.Context, args []string) {
+	if len(args) == 0 || args[0] != "edit" {
+		fmt.Println("Usage: spotify-cli playlist edit [flags] <playlist name or ID>")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("playlist edit", flag.ExitOnError)
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping the state between runs")
+	name := flags.String("name", "", "new name of the playlist")
+	description := flags.String("description", "", "new description of the playlist")
+	public := flags.Bool("public", false, "make the playlist public")
+	private := flags.Bool("private", false, "make the playlist private")
+	flags.Parse(args[1:])
+
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: spotify-cli playlist edit [flags] <playlist name or ID>")
+		os.Exit(2)
+	}
+	if *public && *private {
+		fmt.Println("Error: --public and --private can't be used together")
+		return
+	}
+
+	var details spotify.PlaylistDetails
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			sanitized := sanitizePlaylistName(*name)
+			details.Name = &sanitized
+		case "description":
+			sanitized := sanitizePlaylistDescription(*description)
+			details.Description = &sanitized
+		case "public", "private":
+			visible := *public
+			details.Public = &visible
+		}
+	})
+	if details == (spotify.PlaylistDetails{}) {
+		fmt.Println("Error: nothing to change; give --name, --description, --public or --private")
+		return
+	}
+
+	if err := editPlaylist(ctx, *statePath, flags.Arg(0), details); err != nil {
+		fmt.Println("Error editing playlist:", explainError(err))
+	}
+}
+
+// Function to change the details of a playlist given by its state name or its ID. Renaming a
+// managed playlist keeps it under its monthly name in the state. With --dry-run nothing is changed.
+func editPlaylist(ctx context.Context, statePath, target string, details spotify.PlaylistDetails) error {
+	state, err := loadState(statePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	playlistID := target
+	if name, ok := managedPlaylistName(state, target); ok {
+		playlistID = state.Playlists[name]
+	}
+
+	if dryRun {
+		fmt.Printf("Dry run: would change the details of %s.\n", playlistID)
+		return nil
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+
+	if err := apiClient(token.AccessToken).ChangePlaylistDetails(ctx, playlistID, details); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s.\n", playlistURL(playlistID))
+	return nil
+}
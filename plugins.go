@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Kinds of extension points an external plugin can implement
+const (
+	pluginSource = "source"
+	pluginFilter = "filter"
+	pluginSink   = "sink"
+)
+
+// How long a plugin may take to answer a request before it's killed
+const pluginTimeout = 2 * time.Minute
+
+// An external plugin executable. The tool runs `<path> <kind>` writing a JSON request on stdin
+// and reading a JSON response from stdout; `<path> describe` tells which kinds it implements.
+type plugin struct {
+	Path  string   `json:"-"`
+	Name  string   `json:"name"`
+	Kinds []string `json:"kinds"`
+}
+
+// Request sent to source plugins: the month whose tracks are wanted
+type sourceRequest struct {
+	Month string    `json:"month"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+type sourceResponse struct {
+	Tracks []Track `json:"tracks"`
+	// Provenance label of the tracks (e.g. lastfm-import), the plugin name by default
+	Source string `json:"source,omitempty"`
+}
+
+// Request sent to filter plugins: the tracks that passed the built-in filters
+type filterRequest struct {
+	Tracks []Track `json:"tracks"`
+}
+
+type filterResponse struct {
+	Rejected []struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	} `json:"rejected"`
+}
+
+// Request sent to sink plugins once the sync finished
+type sinkRequest struct {
+	Summary runSummary `json:"summary"`
+	Added   []Track    `json:"added"`
+}
+
+// Flag value collecting every occurrence of a repeated flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Function to load the plugins from their executables, asking each one what it implements
+func loadPlugins(paths []string) ([]*plugin, error) {
+	var plugins []*plugin
+	for _, path := range paths {
+		p := &plugin{Path: path}
+		if err := p.call(context.Background(), "describe", struct{}{}, p); err != nil {
+			return nil, fmt.Errorf("describing plugin %s: %w", path, err)
+		}
+		if p.Name == "" {
+			p.Name = path
+		}
+		slog.Info("Loaded a plugin", "plugin", p.Name, "kinds", strings.Join(p.Kinds, " "))
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+func (p *plugin) implements(kind string) bool {
+	for _, k := range p.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *plugin) call(ctx context.Context, kind string, request, response any) error {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// A plugin that hangs is killed rather than block the run, or the daemon, forever
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path, kind)
+	// Don't wait on the output of children the plugin left running once it's killed
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	if stdout.Len() == 0 || response == nil {
+		return nil
+	}
+	return json.Unmarshal(stdout.Bytes(), response)
+}
+
+// Function to collect the extra tracks provided by the source plugins for the clock's month
+func pluginSourceTracks(ctx context.Context, plugins []*plugin, clock Clock) ([]sourceTracks, error) {
+	request := sourceRequest{Month: clock.Now().Format("2006-01"), AsOf: clock.Now()}
+	var groups []sourceTracks
+	for _, p := range plugins {
+		if !p.implements(pluginSource) {
+			continue
+		}
+		var response sourceResponse
+		if err := p.call(ctx, pluginSource, request, &response); err != nil {
+			return nil, fmt.Errorf("running source plugin %s: %w", p.Name, err)
+		}
+		source := response.Source
+		if source == "" {
+			source = p.Name
+		}
+		slog.Info("A plugin provided tracks", "plugin", p.Name, "tracks", len(response.Tracks), "source", source)
+		groups = append(groups, sourceTracks{Source: source, Tracks: response.Tracks})
+	}
+	return groups, nil
+}
+
+// Function to run the filter plugins over the kept tracks, moving the ones they reject to the skipped list
+func applyPluginFilters(ctx context.Context, plugins []*plugin, kept []Track, skipped []skippedTrack) ([]Track, []skippedTrack, error) {
+	for _, p := range plugins {
+		if !p.implements(pluginFilter) || len(kept) == 0 {
+			continue
+		}
+		var response filterResponse
+		if err := p.call(ctx, pluginFilter, filterRequest{Tracks: kept}, &response); err != nil {
+			return nil, nil, fmt.Errorf("running filter plugin %s: %w", p.Name, err)
+		}
+
+		rejected := map[string]string{}
+		for _, r := range response.Rejected {
+			rejected[r.ID] = r.Reason
+		}
+		var remaining []Track
+		for _, track := range kept {
+			if reason, ok := rejected[track.ID]; ok {
+				if reason == "" {
+					reason = "rejected by " + p.Name
+				}
+				slog.Info("Skipping the track", "track_id", track.ID, "track", track.Name, "artists", artistNames(track), "reason", reason)
+				skipped = append(skipped, skippedTrack{Track: track, Reason: reason})
+				continue
+			}
+			remaining = append(remaining, track)
+		}
+		kept = remaining
+	}
+	return kept, skipped, nil
+}
+
+// Function to hand the outcome of the sync to the sink plugins
+func runPluginSinks(ctx context.Context, plugins []*plugin, summary runSummary, added []Track) error {
+	for _, p := range plugins {
+		if !p.implements(pluginSink) {
+			continue
+		}
+		if err := p.call(ctx, pluginSink, sinkRequest{Summary: summary, Added: added}, nil); err != nil {
+			return fmt.Errorf("running sink plugin %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
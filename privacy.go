@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/eduardohitek/spotify-cli/spotify"
+)
+
+// Visibility of a managed playlist against the policy: private unless --public, and not
+// collaborative
+type playlistPrivacy struct {
+	Name          string `json:"name"`
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Public        bool   `json:"public"`
+	Collaborative bool   `json:"collaborative"`
+	// ok, deleted, or the changes the playlist needs, was given or would be given
+	Status string `json:"status"`
+}
+
+// Function to list the visibility of the managed playlists, fixing the ones off the policy with --fix
+func runAuditPrivacy(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("audit-privacy", flag.ExitOnError)
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping the state between runs")
+	fix := flags.Bool("fix", false, "change the playlists off the policy: private, or public with --public, and not collaborative")
+	allowCollaborative := flags.Bool("allow-collaborative", false, "leave the collaborative playlists collaborative, which keeps them private")
+	format := flags.String("format", "table", "output format: "+formatNames())
+	tmpl := flags.String("template", "", "Go template used by --format template")
+	flags.Parse(args)
+
+	audits, err := auditPrivacy(ctx, *statePath, *fix, *allowCollaborative)
+	if err != nil {
+		fmt.Println("Error auditing playlists:", explainError(err))
+		return
+	}
+	if err := writeReport(os.Stdout, *format, *tmpl, privacyReport(audits)); err != nil {
+		fmt.Println("Error writing audit:", err)
+		return
+	}
+	// Like state verify, the playlists left off the policy fail the command, for scripts
+	for _, audit := range audits {
+		if strings.HasPrefix(audit.Status, "to fix") {
+			os.Exit(1)
+		}
+	}
+}
+
+// Function to read the visibility of every managed playlist, changing the ones off the policy
+// when fixing. With --dry-run nothing is changed.
+func auditPrivacy(ctx context.Context, statePath string, fix, allowCollaborative bool) ([]playlistPrivacy, error) {
+	state, err := loadState(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("loading state: %w", err)
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	client := apiClient(token.AccessToken)
+
+	var audits []playlistPrivacy
+	for _, name := range playlistNames(state) {
+		playlistID := state.Playlists[name]
+		audit := playlistPrivacy{Name: name, ID: playlistID, URL: playlistURL(playlistID), Status: "ok"}
+		playlist, err := client.Playlist(ctx, playlistID, "id,public,collaborative")
+		if isNotFound(err) {
+			audit.Status = "deleted"
+			audits = append(audits, audit)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		audit.Public, audit.Collaborative = playlist.Public, playlist.Collaborative
+
+		details, changes := privacyChanges(playlist, allowCollaborative)
+		switch {
+		case len(changes) == 0:
+		case !fix:
+			audit.Status = "to fix: " + changes
+		case dryRun:
+			fmt.Printf("Dry run: would change %s: %s.\n", name, changes)
+			audit.Status = "would fix: " + changes
+		default:
+			if err := client.ChangePlaylistDetails(ctx, playlistID, details); err != nil {
+				return nil, fmt.Errorf("fixing %s: %w", name, err)
+			}
+			audit.Status = "fixed: " + changes
+			if details.Public != nil {
+				audit.Public = *details.Public
+			}
+			if details.Collaborative != nil {
+				audit.Collaborative = *details.Collaborative
+			}
+		}
+		audits = append(audits, audit)
+	}
+	return audits, nil
+}
+
+// Function to get the details bringing a playlist to the policy and a description of them, both
+// empty when it already follows it. A collaborative playlist that stays one has to stay private.
+func privacyChanges(playlist spotify.Playlist, allowCollaborative bool) (spotify.PlaylistDetails, string) {
+	var details spotify.PlaylistDetails
+	var changes []string
+	collaborative := playlist.Collaborative && allowCollaborative
+	if playlist.Collaborative && !collaborative {
+		details.Collaborative = new(bool)
+		changes = append(changes, "stop collaboration")
+	}
+	if public := playlistsPublic && !collaborative; playlist.Public != public {
+		details.Public = &public
+		if public {
+			changes = append(changes, "make public")
+		} else {
+			changes = append(changes, "make private")
+		}
+	}
+	return details, strings.Join(changes, ", ")
+}
+
+func privacyReport(audits []playlistPrivacy) report {
+	var rows [][]string
+	for _, audit := range audits {
+		rows = append(rows, []string{audit.Name, audit.ID, strconv.FormatBool(audit.Public), strconv.FormatBool(audit.Collaborative), audit.Status})
+	}
+	return report{Columns: []string{"PLAYLIST", "ID", "PUBLIC", "COLLABORATIVE", "STATUS"}, Rows: rows, Data: audits}
+}
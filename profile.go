@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How long the cached profile of the user is used before it's fetched again
+const profileCacheTTL = 24 * time.Hour
+
+// A user profile as cached, keyed by the hash of the refresh token it was fetched with
+type cachedProfile struct {
+	Profile  UserProfile `json:"profile"`
+	CachedAt time.Time   `json:"cached_at"`
+}
+
+// Function to get the profile cache location, next to the token cache; empty when the token
+// cache is disabled
+func profileCachePath() string {
+	if tokenCachePath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(tokenCachePath), "profiles.json")
+}
+
+func loadProfileCache() map[string]cachedProfile {
+	cache := map[string]cachedProfile{}
+	path := profileCachePath()
+	if path == "" {
+		return cache
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		slog.Warn("Ignoring the unreadable profile cache", "path", path, "error", err)
+		return map[string]cachedProfile{}
+	}
+	return cache
+}
+
+// Function to get the profile of the account an app is authorized for, from the profile cache
+// while it's fresh, otherwise from the API. refresh skips the cache.
+func currentProfile(ctx context.Context, accessToken string, app *spotifyApp, refresh bool) (UserProfile, error) {
+	key := refreshTokenHash(app.RefreshToken)
+	cache := loadProfileCache()
+	if cached, ok := cache[key]; ok && !refresh && time.Since(cached.CachedAt) < profileCacheTTL {
+		profilesMu.Lock()
+		defer profilesMu.Unlock()
+		profiles[accessToken] = cached.Profile
+		return cached.Profile, nil
+	}
+
+	profile, err := getCurrentUser(ctx, accessToken)
+	if err != nil {
+		return profile, err
+	}
+	if path := profileCachePath(); path != "" {
+		cache[key] = cachedProfile{Profile: profile, CachedAt: time.Now()}
+		data, err := json.MarshalIndent(cache, "", "  ")
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(path), 0o700)
+		}
+		if err == nil {
+			err = writeFileAtomic(path, append(data, '\n'), 0o600)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Could not save the profile cache", "error", err)
+		}
+	}
+	return profile, nil
+}
+
+// Function to get the name of the account a run syncs, for the summary and notifications; empty
+// when the profile can't be read, which doesn't stop the run
+func runOwner(ctx context.Context, accessToken string, apps *appPool) string {
+	profile, err := currentProfile(ctx, accessToken, apps.active(), false)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not get the current user", "error", err)
+		return ""
+	}
+	return profileName(profile)
+}
+
+// Function to get the name a profile is shown with: its display name, or its ID without one
+func profileName(profile UserProfile) string {
+	if profile.DisplayName != "" {
+		return profile.DisplayName
+	}
+	return profile.ID
+}
+
+// Function to prefix a playlist name with its owner, e.g. Eduardo's Feb'25
+func ownedPlaylistName(owner, playlistName string) string {
+	if owner == "" {
+		return playlistName
+	}
+	return owner + "'s " + playlistName
+}
+
+// Function to show which account the credentials are bound to
+func runWhoami(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("whoami", flag.ExitOnError)
+	refresh := flags.Bool("refresh", false, "fetch the profile again instead of using the cached one")
+	flags.Parse(args)
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		fmt.Println("Not authenticated:", err)
+		return
+	}
+	defer apps.release()
+	app := apps.active()
+	profile, err := currentProfile(ctx, token.AccessToken, app, *refresh)
+	if err != nil {
+		fmt.Println("Error getting current user:", explainError(err))
+		return
+	}
+	fmt.Printf("%s (%s), through the %s app\n", profileName(profile), profile.ID, app.Name)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Notifier publishing to an ntfy topic (https://ntfy.sh or a self-hosted server)
+type ntfyNotifier struct {
+	server string
+	topic  string
+	token  string
+}
+
+// Notifier sending messages to a Gotify server
+type gotifyNotifier struct {
+	server string
+	token  string
+}
+
+// Function to read the push backends: SPOTIFY_NOTIFY_NTFY_TOPIC (with optional _SERVER and _TOKEN)
+// and SPOTIFY_NOTIFY_GOTIFY_URL with SPOTIFY_NOTIFY_GOTIFY_TOKEN
+func pushNotifiersFromEnv() []notifier {
+	var notifiers []notifier
+	if topic := os.Getenv("SPOTIFY_NOTIFY_NTFY_TOPIC"); topic != "" {
+		server := os.Getenv("SPOTIFY_NOTIFY_NTFY_SERVER")
+		if server == "" {
+			server = "https://ntfy.sh"
+		}
+		notifiers = append(notifiers, ntfyNotifier{
+			server: strings.TrimSuffix(server, "/"),
+			topic:  topic,
+			token:  os.Getenv("SPOTIFY_NOTIFY_NTFY_TOKEN"),
+		})
+	}
+	if server := os.Getenv("SPOTIFY_NOTIFY_GOTIFY_URL"); server != "" {
+		notifiers = append(notifiers, gotifyNotifier{
+			server: strings.TrimSuffix(server, "/"),
+			token:  os.Getenv("SPOTIFY_NOTIFY_GOTIFY_TOKEN"),
+		})
+	}
+	return notifiers
+}
+
+func (ntfyNotifier) Name() string {
+	return "ntfy"
+}
+
+func (n ntfyNotifier) Notify(ctx context.Context, msg notification) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", n.server+"/"+n.topic, strings.NewReader(msg.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", msg.Title)
+	req.Header.Set("Tags", "headphones")
+	if msg.URL != "" {
+		req.Header.Set("Click", msg.URL)
+	}
+	if msg.Failure {
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "warning")
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("publishing to ntfy: %s", resp.Status)
+	}
+	return nil
+}
+
+func (gotifyNotifier) Name() string {
+	return "gotify"
+}
+
+func (g gotifyNotifier) Notify(ctx context.Context, msg notification) error {
+	priority := 5
+	if msg.Failure {
+		priority = 8
+	}
+	message := msg.Message
+	if msg.URL != "" {
+		message += "\n" + msg.URL
+	}
+	return postJSON(ctx, g.server+"/message", map[string]any{
+		"title":    msg.Title,
+		"message":  message,
+		"priority": priority,
+	}, http.Header{"X-Gotify-Key": {g.token}})
+}
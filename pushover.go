@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const pushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Notifier sending Pushover messages, with a separate priority for failed syncs
+type pushoverNotifier struct {
+	token           string
+	user            string
+	priority        int
+	failurePriority int
+}
+
+// Function to read the Pushover settings: SPOTIFY_NOTIFY_PUSHOVER_TOKEN and _USER, with optional
+// _PRIORITY (default 0) and _FAILURE_PRIORITY (default 1) between -2 and 2
+func pushoverFromEnv() (pushoverNotifier, bool, error) {
+	p := pushoverNotifier{
+		token:           os.Getenv("SPOTIFY_NOTIFY_PUSHOVER_TOKEN"),
+		user:            os.Getenv("SPOTIFY_NOTIFY_PUSHOVER_USER"),
+		failurePriority: 1,
+	}
+	if p.token == "" || p.user == "" {
+		return p, false, nil
+	}
+
+	for key, priority := range map[string]*int{
+		"SPOTIFY_NOTIFY_PUSHOVER_PRIORITY":         &p.priority,
+		"SPOTIFY_NOTIFY_PUSHOVER_FAILURE_PRIORITY": &p.failurePriority,
+	} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < -2 || parsed > 2 {
+			return p, false, fmt.Errorf("%s must be a priority between -2 and 2", key)
+		}
+		*priority = parsed
+	}
+	return p, true, nil
+}
+
+func (pushoverNotifier) Name() string {
+	return "pushover"
+}
+
+func (p pushoverNotifier) Notify(ctx context.Context, n notification) error {
+	priority := p.priority
+	if n.Failure {
+		priority = p.failurePriority
+	}
+
+	form := url.Values{
+		"token":    {p.token},
+		"user":     {p.user},
+		"title":    {n.Title},
+		"message":  {n.Message},
+		"priority": {strconv.Itoa(priority)},
+	}
+	if n.URL != "" {
+		form.Set("url", n.URL)
+	}
+	// Emergency messages are repeated until acknowledged
+	if priority == 2 {
+		form.Set("retry", "300")
+		form.Set("expire", "3600")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sending to Pushover: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// Function to empty a managed monthly playlist and fill it again from the songs liked that
+// month, in the order the syncs would have added them, e.g. to recover from a messy history.
+// The tracks collaborators added stay.
+func runRebuild(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("rebuild", flag.ExitOnError)
+	monthFlag := flags.String("month", "", "month to rebuild (YYYY-MM), or a day (YYYY-MM-DD) in the period with --period")
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	if *monthFlag == "" {
+		fmt.Println("Error: --month is required, e.g. --month 2024-11")
+		return
+	}
+	month, err := parsePeriodArg(*monthFlag)
+	if err != nil {
+		fmt.Println("Error parsing --month:", err)
+		return
+	}
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if month.After(opts.Clock.Now()) {
+		fmt.Println("Error: --month is in the future")
+		return
+	}
+
+	perform := func(ctx context.Context, opts syncOptions, summary *runSummary) error {
+		return performRebuild(ctx, opts, month, summary)
+	}
+	if _, err := runWithHooks(ctx, opts, perform); err != nil {
+		fmt.Println("Error", err)
+	}
+}
+
+// Function to empty the month's playlist, and its skipped companion, of the tracks the syncing
+// user added and sync the month into them again. Only playlists recorded in the state are
+// rebuilt, and the tracks added by other users are kept like a prune keeps them.
+func performRebuild(ctx context.Context, opts syncOptions, month time.Time, summary *runSummary) error {
+	if opts.LockPath != "" {
+		lock, err := acquireLock(opts.LockPath, opts.LockTTL)
+		if err != nil {
+			return fmt.Errorf("acquiring lock: %w", err)
+		}
+		defer lock.Release()
+	}
+
+	state, err := loadState(opts.StatePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	name := periodPlaylistName(month)
+	playlistID := state.Playlists[name]
+	if playlistID == "" {
+		return fmt.Errorf("%s is not a managed playlist; run sync or adopt first", name)
+	}
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	defer saveTrackCache(opts.Cache)
+	accessToken := token.AccessToken
+	summary.Owner = runOwner(ctx, accessToken, apps)
+	if err := checkSyncScopes(token); err != nil {
+		return err
+	}
+
+	liked, err := fetchLikedSongs(ctx, accessToken, month)
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	songs := liked.Items[:0]
+	for _, song := range liked.Items {
+		if periodStart(song.AddedAt.In(monthZone)).Equal(month) {
+			songs = append(songs, song)
+		}
+	}
+	// Oldest like first when appending, newest first when prepending, as successive syncs leave them
+	sort.SliceStable(songs, func(i, j int) bool {
+		if opts.Prepend {
+			return songs[i].AddedAt.After(songs[j].AddedAt)
+		}
+		return songs[i].AddedAt.Before(songs[j].AddedAt)
+	})
+	tracks := make([]Track, 0, len(songs))
+	for _, song := range songs {
+		tracks = append(tracks, song.Track)
+	}
+
+	profile, err := getCurrentUser(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("getting current user: %w", err)
+	}
+	playlists := []string{name, skippedPlaylistName(name)}
+	for _, playlist := range playlists {
+		id := state.Playlists[playlist]
+		if id == "" {
+			continue
+		}
+		own, err := tracksAddedBy(ctx, accessToken, id, profile.ID)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", playlist, err)
+		}
+		if opts.DryRun {
+			fmt.Printf("Dry run: would remove the %d track(s) you added to %s before filling it again.\n", len(own), playlist)
+			continue
+		}
+		if err := removeFromPlaylist(ctx, accessToken, id, own); err != nil && !isNotFound(err) {
+			return fmt.Errorf("emptying %s: %w", playlist, err)
+		}
+		state.forgetAdded(id, own)
+		slog.InfoContext(ctx, "Emptied the playlist of the tracks the syncing user added", "playlist", playlist, "tracks", len(own))
+	}
+
+	result, err := syncTracks(ctx, accessToken, periodClock(month), tracks, opts, &state)
+	summary.add(result)
+	if opts.Pretty {
+		printSummary(os.Stdout, result, err, useColor(os.Stdout))
+	}
+	if opts.DryRun {
+		return err
+	}
+	// Saved even when the sync stopped partway, the removed and added tracks being recorded
+	if err := saveState(opts.StatePath, state); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rebuilt %s with %d track(s).\n", result.PlaylistName, len(result.Added))
+	return nil
+}
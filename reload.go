@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// How long the config file has to stay unchanged before it's reloaded, editors saving in several steps
+const configSettleDelay = 500 * time.Millisecond
+
+// Global flags of the naming that a reload applies, the others only read at startup
+var namingFlagNames = map[string]bool{"name-format": true, "name-template": true, "name-locale": true}
+
+// Daemon flags that only apply after a restart, their servers being already listening
+var restartFlagNames = map[string]bool{"control-addr": true, "metrics-addr": true}
+
+// Settings of the daemon, read from its arguments and the config file
+type daemonConfig struct {
+	opts  syncOptions
+	sched schedule
+	flags daemonFlags
+	// Naming of the playlists
+	nameFormat   string
+	nameLocale   string
+	nameTemplate *template.Template
+	// Value of every setting, to log what a reload changed
+	values map[string]string
+}
+
+// Function to read the settings of the daemon, the flags given on the command line winning over
+// the config file
+func loadDaemonConfig(args []string, handling flag.ErrorHandling) (daemonConfig, error) {
+	flags := flag.NewFlagSet("daemon", handling)
+	df := registerDaemonFlags(flags)
+	sf := registerSyncFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return daemonConfig{}, err
+	}
+
+	opts, err := sf.options()
+	if err != nil {
+		return daemonConfig{}, err
+	}
+	// The schedule runs on the system clock, each sync being as of its scheduled time
+	if _, frozen := opts.Clock.(fixedClock); frozen {
+		return daemonConfig{}, fmt.Errorf("--as-of can't be used with the daemon, which syncs as of each scheduled time")
+	}
+	sched, err := df.schedule(flags, opts.Clock)
+	if err != nil {
+		return daemonConfig{}, err
+	}
+	cfg := daemonConfig{opts: opts, sched: sched, flags: df, values: map[string]string{}}
+	flags.VisitAll(func(f *flag.Flag) { cfg.values[f.Name] = f.Value.String() })
+
+	naming, err := namingFlags()
+	if err != nil {
+		return daemonConfig{}, err
+	}
+	for name := range namingFlagNames {
+		cfg.values[name] = naming.Lookup(name).Value.String()
+	}
+	cfg.nameFormat, cfg.nameLocale = cfg.values["name-format"], cfg.values["name-locale"]
+	if cfg.nameTemplate, err = parseNameTemplate(cfg.values["name-template"], cfg.nameLocale); err != nil {
+		return daemonConfig{}, fmt.Errorf("parsing --name-template: %w", err)
+	}
+	return cfg, nil
+}
+
+// Function to resolve the naming flags again from the config file, starting over from the
+// defaults and the global flags given on the command line
+func namingFlags() (*flag.FlagSet, error) {
+	flags := flag.NewFlagSet("spotify-cli", flag.ContinueOnError)
+	registerGlobalFlags(flags)
+	if globalFlagSet != nil {
+		for name := range namingFlagNames {
+			f := globalFlagSet.Lookup(name)
+			value := f.DefValue
+			if globalCLIFlags[name] {
+				value = f.Value.String()
+			}
+			flags.Set(name, value)
+		}
+	}
+	if _, errs := applySettings(flags, globalSettings(), globalCLIFlags, namingFlagNames); len(errs) > 0 {
+		return nil, fmt.Errorf("applying the config file: %w", errs[0])
+	}
+	return flags, nil
+}
+
+// Function to name the playlists of the following runs with the naming of the settings
+func (cfg daemonConfig) applyNaming() {
+	playlistNameFormat = cfg.nameFormat
+	playlistNameLocale = cfg.nameLocale
+	playlistNameTemplate = cfg.nameTemplate
+}
+
+// Function to log the settings that differ between two configs, returning whether the schedule changed
+func logConfigChanges(previous, current daemonConfig) bool {
+	var names []string
+	for name, value := range current.values {
+		if previous.values[name] != value {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		slog.Info("Reloaded the config file; no setting changed", "path", configFile)
+		return false
+	}
+	sort.Strings(names)
+	scheduleChanged := false
+	for _, name := range names {
+		if restartFlagNames[name] {
+			slog.Warn("A changed setting only applies after a restart", "setting", name, "value", current.values[name])
+			continue
+		}
+		slog.Info("Reloaded a setting of the config file", "setting", name, "from", previous.values[name], "to", current.values[name])
+		scheduleChanged = scheduleChanged || name == "every" || name == "cron"
+	}
+	return scheduleChanged
+}
+
+// Function to watch the config file, sending on the returned channel once it changed. Editors
+// often replace the file rather than write it, so its directory is watched.
+func watchConfigFile(ctx context.Context, path string) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+		var settled <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(path) && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					settled = time.After(configSettleDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Could not watch the config file", "path", path, "error", err)
+			case <-settled:
+				settled = nil
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changed, nil
+}
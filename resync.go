@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+// Function to reconcile the monthly playlist of a past month again with the songs liked that
+// month, e.g. after a sync missed some of them
+func runResync(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("resync", flag.ExitOnError)
+	monthFlag := flags.String("month", "", "past month to sync again (YYYY-MM), or a day (YYYY-MM-DD) in the past period with --period")
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	if *monthFlag == "" {
+		fmt.Println("Error: --month is required, e.g. --month 2024-11")
+		return
+	}
+	month, err := parsePeriodArg(*monthFlag)
+	if err != nil {
+		fmt.Println("Error parsing --month:", err)
+		return
+	}
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if !month.Before(periodStart(opts.Clock.Now())) {
+		fmt.Println("Error: --month must be in a past period; sync handles the current one")
+		return
+	}
+
+	perform := func(ctx context.Context, opts syncOptions, summary *runSummary) error {
+		return performBackfill(ctx, opts, month, month, summary)
+	}
+	if _, err := runWithHooks(ctx, opts, perform); err != nil {
+		fmt.Println("Error", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// Most attempts made at each request, set with --max-attempts
+var maxAttempts = 5
+
+// How long each attempt may take, until its response body is read, set with --timeout
+var requestTimeout = 30 * time.Second
+
+// Transport retrying failed requests: rate limited ones after the Retry-After delay, and server
+// errors and network failures with exponential backoff and jitter. Server errors and network
+// failures are only retried for GET, PUT and DELETE, since a POST that failed midway may have
+// been applied already.
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		try, err := rewindRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+		resp, err := t.next.RoundTrip(try.WithContext(ctx))
+		if err != nil {
+			cancel()
+		} else {
+			resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		}
+		if attempt >= maxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if !idempotent(req.Method) {
+				return nil, err
+			}
+			delay = backoff(attempt)
+			slog.WarnContext(req.Context(), "Request failed; retrying", "method", req.Method, "path", req.URL.Path, "error", err, "delay", delay)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			delay = retryAfter(resp, attempt)
+			slog.WarnContext(req.Context(), "Rate limited by Spotify; retrying", "method", req.Method, "path", req.URL.Path, "delay", delay)
+		case resp.StatusCode >= http.StatusInternalServerError && idempotent(req.Method):
+			delay = backoff(attempt)
+			slog.WarnContext(req.Context(), "Request got a server error; retrying", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "delay", delay)
+		default:
+			return resp, nil
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// Response body ending the timeout of its attempt once closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+}
+
+// Function to get the delay before the next attempt: a random one up to the exponentially
+// growing ceiling, so concurrent runs don't retry in lockstep
+func backoff(attempt int) time.Duration {
+	ceiling := min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+	return ceiling/2 + rand.N(ceiling/2)
+}
+
+// Function to get the delay Spotify asks for in the Retry-After header, in seconds or as a
+// date, falling back to the backoff when it's missing
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return backoff(attempt)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// When the daemon runs: at a fixed interval, or at the times matched by a cron expression
+type schedule interface {
+	// Function to get the first run time after t
+	Next(t time.Time) time.Time
+}
+
+// Schedule running every interval
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.every)
+}
+
+// Schedule of a standard five-field cron expression, minute hour day-of-month month day-of-week,
+// each field holding the allowed values
+type cronSchedule struct {
+	minute, hour, day, month, weekday map[int]bool
+	// Whether the day-of-month and day-of-week fields are restricted: when both are, a day
+	// matching either of them matches, as in cron
+	anyDay, anyWeekday bool
+}
+
+// Shorthands of the common cron expressions
+var cronShorthands = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// Function to parse a cron expression, e.g. 0 */6 * * * for every six hours, or a shorthand
+// like @daily. Each field takes *, values, ranges (1-5), lists (1,15) and steps (*/15).
+func parseCron(expr string) (cronSchedule, error) {
+	if full, ok := cronShorthands[strings.TrimSpace(expr)]; ok {
+		expr = full
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("expected 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return s, fmt.Errorf("minute: %w", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return s, fmt.Errorf("hour: %w", err)
+	}
+	if s.day, err = parseCronField(fields[2], 1, 31); err != nil {
+		return s, fmt.Errorf("day of month: %w", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return s, fmt.Errorf("month: %w", err)
+	}
+	if s.weekday, err = parseCronField(fields[4], 0, 7); err != nil {
+		return s, fmt.Errorf("day of week: %w", err)
+	}
+	// Sunday is both 0 and 7
+	if s.weekday[7] {
+		s.weekday[0] = true
+	}
+	s.anyDay = fields[2] == "*"
+	s.anyWeekday = fields[4] == "*"
+	return s, nil
+}
+
+// Function to parse a cron field into its values between low and high
+func parseCronField(field string, low, high int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		first, last := low, high
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if first, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", from)
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid value %q", to)
+				}
+			} else if hasStep {
+				last = high
+			}
+		}
+		if first < low || last > high || first > last {
+			return nil, fmt.Errorf("%q is out of the range %d-%d", rangePart, low, high)
+		}
+		for v := first; v <= last; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Function to check whether the day of t matches the day-of-month and day-of-week fields
+func (s cronSchedule) matchesDay(t time.Time) bool {
+	day, weekday := s.day[t.Day()], s.weekday[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+func (s cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every expression matches within a few years, e.g. the 29th of February
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case !s.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Function to build the daemon's schedule from --cron, or else --every, checking that the cron
+// expression matches a time after the clock's
+func parseSchedule(cron string, every time.Duration, clock Clock) (schedule, error) {
+	if cron != "" {
+		s, err := parseCron(cron)
+		if err != nil {
+			return nil, fmt.Errorf("parsing --cron: %w", err)
+		}
+		if s.Next(clock.Now()).IsZero() {
+			return nil, fmt.Errorf("parsing --cron: %q never matches", cron)
+		}
+		return s, nil
+	}
+	if every < time.Minute {
+		return nil, fmt.Errorf("--every must be at least 1m")
+	}
+	return intervalSchedule{every: every}, nil
+}
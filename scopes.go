@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Features of the tool that need authorization scopes
+type feature string
+
+const (
+	featureReadLibrary    feature = "read-library"
+	featureReadPlaylists  feature = "read-playlists"
+	featureWritePlaylists feature = "write-playlists"
+	// Liking and unliking songs, with the like and unlike commands
+	featureWriteLibrary feature = "write-library"
+	// Creating the playlists as public, with --public
+	featureWritePublicPlaylists feature = "write-public-playlists"
+)
+
+// Scopes needed by each feature, so the app only asks for what is actually enabled
+var featureScopes = map[feature][]string{
+	featureReadLibrary:          {"user-library-read"},
+	featureReadPlaylists:        {"playlist-read-private"},
+	featureWritePlaylists:       {"playlist-modify-private"},
+	featureWriteLibrary:         {"user-library-modify"},
+	featureWritePublicPlaylists: {"playlist-modify-public"},
+}
+
+// Function to list the features a sync uses with the current settings
+func syncFeatures() []feature {
+	features := []feature{featureReadLibrary, featureReadPlaylists, featureWritePlaylists}
+	if playlistsPublic {
+		features = append(features, featureWritePublicPlaylists)
+	}
+	return features
+}
+
+// Features of the read-only commands, export and status, which auth login --read-only asks for
+func readOnlyFeatures() []feature {
+	return []feature{featureReadLibrary}
+}
+
+// Function to list the features auth login asks for: those of a sync with the current settings,
+// or the read-only ones, and the library changes when requested
+func loginFeatures(readOnly, libraryWrite bool) []feature {
+	features := syncFeatures()
+	if readOnly {
+		features = readOnlyFeatures()
+	}
+	if libraryWrite {
+		features = append(features, featureWriteLibrary)
+	}
+	return features
+}
+
+// Function to get the auth login command granting the scopes of the features, with the scopes
+// it asks for, so enabling a feature that needs more scopes tells how to get them
+func loginCommand(features []feature) (string, []string) {
+	command := "spotify-cli auth login"
+	if slices.Contains(features, featureWritePublicPlaylists) {
+		command = "spotify-cli --public auth login"
+	}
+	readOnly := true
+	for _, f := range features {
+		readOnly = readOnly && slices.Contains(readOnlyFeatures(), f)
+	}
+	if readOnly {
+		command += " --read-only"
+	}
+	libraryWrite := slices.Contains(features, featureWriteLibrary)
+	if libraryWrite {
+		command += " --library-write"
+	}
+	return command, requiredScopes(loginFeatures(readOnly, libraryWrite)...)
+}
+
+// Function to make sure the token was granted every scope the features need, telling how to
+// authorize the app again otherwise. A token without scopes, e.g. of a refresh token obtained
+// before the tool asked for them, has unknown ones, which can't be trusted to suffice.
+func checkScopes(token AccessTokenResponse, features ...feature) error {
+	command, scopes := loginCommand(features)
+	if token.Scope == "" {
+		return fmt.Errorf("checking scopes: the scopes granted to the refresh token are unknown; authorize the app again with %s, requesting: %s",
+			command, strings.Join(scopes, " "))
+	}
+	if missing := missingScopes(token.Scope, requiredScopes(features...)); len(missing) > 0 {
+		return fmt.Errorf("checking scopes: the refresh token is missing the scope(s) %s; authorize the app again with %s, requesting: %s",
+			strings.Join(missing, ", "), command, strings.Join(scopes, " "))
+	}
+	return nil
+}
+
+// Function to compute the minimal scope set for the enabled features
+func requiredScopes(features ...feature) []string {
+	set := map[string]bool{}
+	for _, f := range features {
+		for _, scope := range featureScopes[f] {
+			set[scope] = true
+		}
+	}
+
+	scopes := make([]string, 0, len(set))
+	for scope := range set {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// Function to list the required scopes that were not granted, given the space-separated scope field of a token response
+func missingScopes(granted string, required []string) []string {
+	grantedSet := map[string]bool{}
+	for _, scope := range strings.Fields(granted) {
+		grantedSet[scope] = true
+	}
+
+	var missing []string
+	for _, scope := range required {
+		if !grantedSet[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const openSpotifyURL = "https://open.spotify.com"
+
+// Function to build the spotify: deep link for a track
+func trackURI(track Track) string {
+	return "spotify:track:" + track.ID
+}
+
+// Function to build the open.spotify.com web link for a track
+func trackURL(track Track) string {
+	return openSpotifyURL + "/track/" + track.ID
+}
+
+// Function to build the open.spotify.com web link for a playlist
+func playlistURL(playlistID string) string {
+	return openSpotifyURL + "/playlist/" + playlistID
+}
+
+func artistNames(track Track) string {
+	return strings.Join(artistList(track), ", ")
+}
+
+func artistList(track Track) []string {
+	names := make([]string, 0, len(track.Artists))
+	for _, artist := range track.Artists {
+		names = append(names, artist.Name)
+	}
+	return names
+}
+
+// Function to write a shortlist of links for the given tracks, ready to paste into a chat.
+// A path of "-" prints the shortlist to stdout.
+func writeShortlist(path string, tracks []Track) error {
+	if path == "-" {
+		return formatShortlist(os.Stdout, tracks)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := formatShortlist(file, tracks); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func formatShortlist(w io.Writer, tracks []Track) error {
+	for _, track := range tracks {
+		if _, err := fmt.Fprintf(w, "%s - %s\n%s\n%s\n\n", track.Name, artistNames(track), trackURI(track), trackURL(track)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
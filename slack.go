@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const slackMaxRequestAge = 5 * time.Minute
+
+// Server answering Slack slash commands like `/likedsongs sync` and `/likedsongs month Feb`
+type slackServer struct {
+	// Lifetime of the server, cancelling the syncs started in the background
+	ctx           context.Context
+	signingSecret string
+	opts          syncOptions
+	// Only one command touches the API at a time
+	busy sync.Mutex
+}
+
+// Function to serve the Slack slash-command endpoint
+func runSlack(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("slack", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	secret := os.Getenv("SLACK_SIGNING_SECRET")
+	if secret == "" {
+		fmt.Println("Error: SLACK_SIGNING_SECRET is required to verify the slash-command requests")
+		return
+	}
+
+	server := &slackServer{ctx: ctx, signingSecret: secret, opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /slack/command", server.handleCommand)
+
+	slog.Info("Listening for Slack slash commands on /slack/command", "addr", *addr)
+	if err := serve(ctx, *addr, mux); err != nil {
+		fmt.Println("Error serving:", err)
+	}
+}
+
+// Function to check the X-Slack-Signature of a request, as described in Slack's request verification docs
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing request timestamp")
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return fmt.Errorf("request timestamp is too old")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:%s", timestamp, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+func (s *slackServer) handleCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "could not read request", http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackSignature(s.signingSecret, r.Header, body, time.Now()); err != nil {
+		slog.Warn("Rejected a Slack request", "error", err)
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	fields := strings.Fields(form.Get("text"))
+	if len(fields) == 0 {
+		fields = []string{"help"}
+	}
+	switch fields[0] {
+	case "sync":
+		s.startSync(w, form.Get("response_url"))
+	case "month":
+		s.month(r.Context(), w, strings.Join(fields[1:], " "))
+	default:
+		writeSlackResponse(w, "Usage: `sync` to sync the liked songs now, `month Feb` (or `month 2025-02`) to show a monthly playlist.")
+	}
+}
+
+// Function to start a sync in the background, posting its outcome to the command's response URL
+func (s *slackServer) startSync(w http.ResponseWriter, responseURL string) {
+	if !s.busy.TryLock() {
+		writeSlackResponse(w, "A sync is already running.")
+		return
+	}
+	writeSlackResponse(w, "Sync started…")
+
+	go func() {
+		defer s.busy.Unlock()
+		summary, err := runSyncWithHooks(s.ctx, s.opts)
+		text := syncOutcomeText(summary, err)
+		if responseURL == "" {
+			return
+		}
+		if err := postJSON(s.ctx, responseURL, map[string]string{"response_type": "in_channel", "text": text}, nil); err != nil {
+			slog.Warn("Could not post the sync outcome to Slack", "error", err)
+		}
+	}()
+}
+
+// Function to answer with the tracks of a monthly playlist
+func (s *slackServer) month(ctx context.Context, w http.ResponseWriter, value string) {
+	month, err := parseMonthArgument(value, nowInMonthZone())
+	if err != nil {
+		writeSlackResponse(w, err.Error())
+		return
+	}
+	if !s.busy.TryLock() {
+		writeSlackResponse(w, "A sync is running; try again in a moment.")
+		return
+	}
+	defer s.busy.Unlock()
+
+	playlistID, err := findMonthlyPlaylist(ctx, month.Format("2006-01"), s.opts.StatePath)
+	if err != nil {
+		writeSlackResponse(w, "Could not find the playlist: "+err.Error())
+		return
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		writeSlackResponse(w, "Could not authenticate: "+err.Error())
+		return
+	}
+	defer apps.release()
+	tracks, err := getPlaylistTracks(ctx, token.AccessToken, playlistID)
+	if err != nil {
+		writeSlackResponse(w, "Could not read the playlist: "+err.Error())
+		return
+	}
+
+	lines := []string{fmt.Sprintf("*%s* (%d track(s)) <%s>", periodPlaylistName(month), len(tracks), playlistURL(playlistID))}
+	for _, track := range tracks {
+		lines = append(lines, fmt.Sprintf("• %s - %s", track.Name, artistNames(track)))
+	}
+	writeSlackResponse(w, strings.Join(lines, "\n"))
+}
+
+// Function to parse a month given as 2025-02, Feb, February or Feb 2024; without a year,
+// the latest such month up to now is used
+func parseMonthArgument(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01", "Jan 2006", "January 2006"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"Jan", "January"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			month := time.Date(now.Year(), t.Month(), 1, 0, 0, 0, 0, now.Location())
+			if month.After(now) {
+				month = month.AddDate(-1, 0, 0)
+			}
+			return month, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a month like Feb or 2025-02, got %q", value)
+}
+
+func writeSlackResponse(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"response_type": "ephemeral", "text": text})
+}
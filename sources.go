@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// How tracks arriving from several sources are recognized as the same one
+const (
+	dedupeByID   = "id"
+	dedupeByISRC = "isrc"
+)
+
+// Tracks provided by one source, labelled with its provenance
+type sourceTracks struct {
+	Source string
+	Tracks []Track
+}
+
+// Function to merge the tracks of the sources into one list, the sources listed in priority
+// first, then the others in their original order. A track provided by several sources is kept
+// once, attributed to the one with the highest priority. With dedupeByISRC, different releases
+// of the same recording count as the same track. The provenance of the tracks not coming from
+// the liked songs is returned keyed by track ID.
+func mergeSources(groups []sourceTracks, priority []string, dedupe string) ([]Track, map[string]string) {
+	rank := func(source string) int {
+		for i, p := range priority {
+			if p == source {
+				return i
+			}
+		}
+		return len(priority)
+	}
+	ordered := append([]sourceTracks(nil), groups...)
+	sort.SliceStable(ordered, func(i, j int) bool { return rank(ordered[i].Source) < rank(ordered[j].Source) })
+
+	var merged []Track
+	sources := map[string]string{}
+	seen := map[string]bool{}
+	for _, group := range ordered {
+		for _, track := range group.Tracks {
+			key := track.ID
+			if dedupe == dedupeByISRC && track.ExternalIDs.ISRC != "" {
+				key = "isrc:" + strings.ToUpper(track.ExternalIDs.ISRC)
+			}
+			if seen[key] || seen[track.ID] {
+				continue
+			}
+			seen[key], seen[track.ID] = true, true
+			merged = append(merged, track)
+			if group.Source != provenanceLikedSong {
+				sources[track.ID] = group.Source
+			}
+		}
+	}
+	return merged, sources
+}
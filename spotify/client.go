@@ -0,0 +1,157 @@
+// Package spotify is a small client for the parts of the Spotify Web API the tool uses:
+// the user's profile, their liked songs and their playlists.
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the base URL of the Spotify Web API
+const DefaultBaseURL = "https://api.spotify.com/v1"
+
+// TokenSource provides the access token sent with every request
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource always returning the same access token
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Client calls the Spotify Web API on behalf of a user
+type Client struct {
+	// BaseURL defaults to DefaultBaseURL; tests point it at a local server
+	BaseURL string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+	Tokens     TokenSource
+}
+
+// NewClient returns a client authenticating with the given tokens and sending its requests
+// through httpClient, or http.DefaultClient when it is nil
+func NewClient(tokens TokenSource, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{BaseURL: DefaultBaseURL, HTTPClient: httpClient, Tokens: tokens}
+}
+
+// Error is returned when the API answers with a non-2xx status, with the message of the
+// error object Spotify sends along
+type Error struct {
+	Method  string
+	Path    string
+	Status  int
+	Message string
+	// RetryAfter is how long Spotify asks to wait before the next request, from the
+	// Retry-After header of 429 responses
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.Status)
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.Status, message)
+}
+
+// Function to build the error of a non-2xx response from its body, which is
+// {"error": {"status": ..., "message": ...}} for most endpoints
+func responseError(method, path string, resp *http.Response) *Error {
+	var body struct {
+		Error struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	json.Unmarshal(data, &body)
+	apiErr := &Error{Method: method, Path: path, Status: resp.StatusCode, Message: body.Error.Message}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// Do calls an endpoint the client has no method for, the same way the methods do: with the
+// token source's access token, through the HTTP client (and so its transport's retries and rate
+// limiting) and returning an *Error for non-2xx statuses. path is relative to the base URL and
+// may carry a query string (e.g. "/me/top/tracks?limit=10"); body, when not nil, is sent as JSON
+// and the response is decoded into out when it's not nil.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	path, rawQuery, _ := strings.Cut(path, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return fmt.Errorf("parsing the query of %s: %w", path, err)
+	}
+	return c.do(ctx, method, path, query, body, out)
+}
+
+// Function to send a request to path (relative to the base URL), encoding body as JSON when
+// it's not nil and decoding the response into out when it's not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	token, err := c.Tokens.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(method, path, resp)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func pageQuery(limit, offset int) url.Values {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", fmt.Sprint(offset))
+	}
+	return query
+}
@@ -0,0 +1,229 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Function to start a server answering with handler and a client calling it with the token "test"
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := NewClient(StaticToken("test"), server.Client())
+	client.BaseURL = server.URL
+	return client
+}
+
+func TestErrorParsesTheErrorObject(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantMessage string
+		wantError   string
+	}{
+		{"error object", http.StatusNotFound, `{"error": {"status": 404, "message": "Non existing id"}}`, "Non existing id", "GET /me: 404 Non existing id"},
+		{"empty body", http.StatusForbidden, ``, "", "GET /me: 403 Forbidden"},
+		{"not JSON", http.StatusBadGateway, `<html>Bad gateway</html>`, "", "GET /me: 502 Bad Gateway"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+			_, err := client.CurrentUser(context.Background())
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if apiErr.Status != tt.status || apiErr.Message != tt.wantMessage {
+				t.Errorf("got status %d and message %q, want %d and %q", apiErr.Status, apiErr.Message, tt.status, tt.wantMessage)
+			}
+			if err.Error() != tt.wantError {
+				t.Errorf("got %q, want %q", err.Error(), tt.wantError)
+			}
+		})
+	}
+}
+
+func TestErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"7", 7 * time.Second},
+		{"0", 0},
+		{"", 0},
+		{"Wed, 21 Oct 2026 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Quote(tt.header), func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				io.WriteString(w, `{"error": {"status": 429, "message": "API rate limit exceeded"}}`)
+			})
+			err := client.SaveTracks(context.Background(), []string{"a"})
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests {
+				t.Fatalf("got %v, want a 429 *Error", err)
+			}
+			if apiErr.RetryAfter != tt.want {
+				t.Errorf("got RetryAfter %s, want %s", apiErr.RetryAfter, tt.want)
+			}
+		})
+	}
+}
+
+func TestLikedSongsPagination(t *testing.T) {
+	const total = 7
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/me/tracks" {
+			t.Errorf("got a request to %s, want /me/tracks", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test" {
+			t.Errorf("got Authorization %q, want the token of the token source", got)
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		page := Page[SavedTrack]{Limit: limit, Offset: offset, Total: total}
+		for i := offset; i < min(offset+limit, total); i++ {
+			page.Items = append(page.Items, SavedTrack{Track: Track{ID: fmt.Sprintf("track%d", i)}})
+		}
+		if offset+limit < total {
+			page.Next = fmt.Sprintf("%s?offset=%d&limit=%d", r.URL.Path, offset+limit, limit)
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+
+	var ids []string
+	pages := 0
+	for offset := 0; ; {
+		page, err := client.LikedSongs(context.Background(), PageOptions{Limit: 3, Offset: offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, song := range page.Items {
+			ids = append(ids, song.Track.ID)
+		}
+		if page.Next == "" {
+			break
+		}
+		offset += len(page.Items)
+	}
+	want := []string{"track0", "track1", "track2", "track3", "track4", "track5", "track6"}
+	if !reflect.DeepEqual(ids, want) || pages != 3 {
+		t.Errorf("got %v in %d pages, want %v in 3", ids, pages, want)
+	}
+}
+
+func TestPageQueryLeavesOutDefaults(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "" {
+			t.Errorf("got query %q, want none for the default page", r.URL.RawQuery)
+		}
+		io.WriteString(w, `{"items": []}`)
+	})
+	if _, err := client.LikedSongs(context.Background(), PageOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Function to start a server recording the method, path and JSON body of the one request it
+// gets, answering with a snapshot ID
+func recordRequest(t *testing.T, method, path *string, body *map[string]any) *Client {
+	return newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		*method, *path = r.Method, r.URL.Path
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("got Content-Type %q, want application/json", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			t.Errorf("decoding the request body: %v", err)
+		}
+		io.WriteString(w, `{"snapshot_id": "snapshot2"}`)
+	})
+}
+
+func TestAddTracksBody(t *testing.T) {
+	position := 0
+	tests := []struct {
+		name string
+		opts AddTracksOptions
+		want map[string]any
+	}{
+		{"append", AddTracksOptions{}, map[string]any{"uris": []any{"spotify:track:a", "spotify:track:b"}}},
+		{"prepend", AddTracksOptions{Position: &position}, map[string]any{"uris": []any{"spotify:track:a", "spotify:track:b"}, "position": 0.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path string
+			var body map[string]any
+			client := recordRequest(t, &method, &path, &body)
+			snapshot, err := client.AddTracks(context.Background(), "playlist1", []string{"spotify:track:a", "spotify:track:b"}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method != "POST" || path != "/playlists/playlist1/tracks" {
+				t.Errorf("got %s %s, want POST /playlists/playlist1/tracks", method, path)
+			}
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("got body %v, want %v", body, tt.want)
+			}
+			if snapshot != "snapshot2" {
+				t.Errorf("got snapshot %q, want snapshot2", snapshot)
+			}
+		})
+	}
+}
+
+func TestRemoveTracksBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracks   []TrackToRemove
+		snapshot string
+		want     map[string]any
+	}{
+		{
+			"every occurrence",
+			[]TrackToRemove{{URI: "spotify:track:a"}},
+			"",
+			map[string]any{"tracks": []any{map[string]any{"uri": "spotify:track:a"}}},
+		},
+		{
+			"positions of a snapshot",
+			[]TrackToRemove{{URI: "spotify:track:a", Positions: []int{0, 4}}},
+			"snapshot1",
+			map[string]any{"tracks": []any{map[string]any{"uri": "spotify:track:a", "positions": []any{0.0, 4.0}}}, "snapshot_id": "snapshot1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path string
+			var body map[string]any
+			client := recordRequest(t, &method, &path, &body)
+			if _, err := client.RemoveTracks(context.Background(), "playlist1", tt.tracks, tt.snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if method != "DELETE" || path != "/playlists/playlist1/tracks" {
+				t.Errorf("got %s %s, want DELETE /playlists/playlist1/tracks", method, path)
+			}
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("got body %v, want %v", body, tt.want)
+			}
+		})
+	}
+}
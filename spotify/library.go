@@ -0,0 +1,91 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Most items Tracks and Artists accept at once
+const (
+	MaxTracksPerLookup  = 50
+	MaxArtistsPerLookup = 50
+)
+
+// CurrentUser gets the profile of the user the client authenticates as
+func (c *Client) CurrentUser(ctx context.Context) (User, error) {
+	var user User
+	err := c.do(ctx, "GET", "/me", nil, nil, &user)
+	return user, err
+}
+
+// PageOptions selects a page of a paginated endpoint; zero values use Spotify's defaults
+type PageOptions struct {
+	Limit  int
+	Offset int
+	// Limits the response to the given fields where the endpoint supports it
+	// (e.g. "items(track(id)),next")
+	Fields string
+}
+
+// LikedSongs gets a page of the user's liked songs, newest first
+func (c *Client) LikedSongs(ctx context.Context, opts PageOptions) (Page[SavedTrack], error) {
+	var page Page[SavedTrack]
+	err := c.do(ctx, "GET", "/me/tracks", pageQuery(opts.Limit, opts.Offset), nil, &page)
+	return page, err
+}
+
+// Tracks gets the tracks with the given IDs, at most MaxTracksPerLookup of them; unknown IDs
+// are left out
+func (c *Client) Tracks(ctx context.Context, ids []string) ([]Track, error) {
+	var response struct {
+		Tracks []*Track `json:"tracks"`
+	}
+	query := url.Values{"ids": {strings.Join(ids, ",")}}
+	if err := c.do(ctx, "GET", "/tracks", query, nil, &response); err != nil {
+		return nil, err
+	}
+
+	tracks := make([]Track, 0, len(response.Tracks))
+	for _, track := range response.Tracks {
+		if track != nil {
+			tracks = append(tracks, *track)
+		}
+	}
+	return tracks, nil
+}
+
+// Artists gets the artists with the given IDs, with their genres, at most MaxArtistsPerLookup
+// of them; unknown IDs are left out
+func (c *Client) Artists(ctx context.Context, ids []string) ([]Artist, error) {
+	var response struct {
+		Artists []*Artist `json:"artists"`
+	}
+	query := url.Values{"ids": {strings.Join(ids, ",")}}
+	if err := c.do(ctx, "GET", "/artists", query, nil, &response); err != nil {
+		return nil, err
+	}
+
+	artists := make([]Artist, 0, len(response.Artists))
+	for _, artist := range response.Artists {
+		if artist != nil {
+			artists = append(artists, *artist)
+		}
+	}
+	return artists, nil
+}
+
+// Most tracks SaveTracks and RemoveSavedTracks accept at once
+const MaxTracksPerLibraryChange = 50
+
+// SaveTracks adds the tracks with the given IDs to the user's liked songs, at most
+// MaxTracksPerLibraryChange of them
+func (c *Client) SaveTracks(ctx context.Context, ids []string) error {
+	return c.do(ctx, "PUT", "/me/tracks", url.Values{"ids": {strings.Join(ids, ",")}}, nil, nil)
+}
+
+// RemoveSavedTracks removes the tracks with the given IDs from the user's liked songs, at most
+// MaxTracksPerLibraryChange of them
+func (c *Client) RemoveSavedTracks(ctx context.Context, ids []string) error {
+	return c.do(ctx, "DELETE", "/me/tracks", url.Values{"ids": {strings.Join(ids, ",")}}, nil, nil)
+}
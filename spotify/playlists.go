@@ -0,0 +1,136 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+)
+
+// Playlists gets a page of the playlists the user owns or follows
+func (c *Client) Playlists(ctx context.Context, opts PageOptions) (Page[Playlist], error) {
+	var page Page[Playlist]
+	err := c.do(ctx, "GET", "/me/playlists", pageQuery(opts.Limit, opts.Offset), nil, &page)
+	return page, err
+}
+
+// Playlist gets a playlist; fields, when not empty, limits the response to the given fields
+// (e.g. "id,description")
+func (c *Client) Playlist(ctx context.Context, playlistID, fields string) (Playlist, error) {
+	var playlist Playlist
+	query := url.Values{}
+	if fields != "" {
+		query.Set("fields", fields)
+	}
+	err := c.do(ctx, "GET", "/playlists/"+playlistID, query, nil, &playlist)
+	return playlist, err
+}
+
+// PlaylistTracks gets a page of the items of a playlist
+func (c *Client) PlaylistTracks(ctx context.Context, playlistID string, opts PageOptions) (Page[PlaylistTrack], error) {
+	var page Page[PlaylistTrack]
+	query := pageQuery(opts.Limit, opts.Offset)
+	if opts.Fields != "" {
+		query.Set("fields", opts.Fields)
+	}
+	err := c.do(ctx, "GET", "/playlists/"+playlistID+"/tracks", query, nil, &page)
+	return page, err
+}
+
+// NewPlaylist describes a playlist to create
+type NewPlaylist struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+}
+
+// CreatePlaylist creates a playlist in the account of the given user
+func (c *Client) CreatePlaylist(ctx context.Context, userID string, playlist NewPlaylist) (Playlist, error) {
+	var created Playlist
+	err := c.do(ctx, "POST", "/users/"+userID+"/playlists", nil, playlist, &created)
+	return created, err
+}
+
+// PlaylistDetails holds the details of a playlist to change; nil fields are left as they are
+type PlaylistDetails struct {
+	Name          *string `json:"name,omitempty"`
+	Description   *string `json:"description,omitempty"`
+	Public        *bool   `json:"public,omitempty"`
+	Collaborative *bool   `json:"collaborative,omitempty"`
+}
+
+// ChangePlaylistDetails changes the name, description or visibility of a playlist
+func (c *Client) ChangePlaylistDetails(ctx context.Context, playlistID string, details PlaylistDetails) error {
+	return c.do(ctx, "PUT", "/playlists/"+playlistID, nil, details, nil)
+}
+
+// AddTracksOptions tells where the tracks go; a nil Position appends them
+type AddTracksOptions struct {
+	Position *int
+}
+
+type addTracksRequest struct {
+	URIs     []string `json:"uris"`
+	Position *int     `json:"position,omitempty"`
+}
+
+// AddTracks adds the tracks with the given URIs (spotify:track:...) to a playlist, returning
+// the new snapshot ID of the playlist
+func (c *Client) AddTracks(ctx context.Context, playlistID string, uris []string, opts AddTracksOptions) (string, error) {
+	var response struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	err := c.do(ctx, "POST", "/playlists/"+playlistID+"/tracks", nil, addTracksRequest{URIs: uris, Position: opts.Position}, &response)
+	return response.SnapshotID, err
+}
+
+// FollowPlaylist adds a playlist to the user's library, showing it on their profile when public
+func (c *Client) FollowPlaylist(ctx context.Context, playlistID string, public bool) error {
+	return c.do(ctx, "PUT", "/playlists/"+playlistID+"/followers", nil, map[string]bool{"public": public}, nil)
+}
+
+// Most tracks ReplaceTracks accepts at once
+const MaxTracksPerReplace = 100
+
+// ReplaceTracks replaces the items of a playlist with the tracks with the given URIs, at most
+// MaxTracksPerReplace of them; no URIs empties the playlist. It returns the new snapshot ID.
+func (c *Client) ReplaceTracks(ctx context.Context, playlistID string, uris []string) (string, error) {
+	var response struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if uris == nil {
+		uris = []string{}
+	}
+	err := c.do(ctx, "PUT", "/playlists/"+playlistID+"/tracks", nil, map[string][]string{"uris": uris}, &response)
+	return response.SnapshotID, err
+}
+
+// UnfollowPlaylist removes a playlist from the user's library, which is how Spotify deletes
+// the playlists the user owns
+func (c *Client) UnfollowPlaylist(ctx context.Context, playlistID string) error {
+	return c.do(ctx, "DELETE", "/playlists/"+playlistID+"/followers", nil, nil, nil)
+}
+
+// Most tracks RemoveTracks accepts at once
+const MaxTracksPerRemoval = 100
+
+// TrackToRemove names a track to remove from a playlist by URI; with Positions only the
+// occurrences at those positions are removed, otherwise all of them
+type TrackToRemove struct {
+	URI       string `json:"uri"`
+	Positions []int  `json:"positions,omitempty"`
+}
+
+type removeTracksRequest struct {
+	Tracks     []TrackToRemove `json:"tracks"`
+	SnapshotID string          `json:"snapshot_id,omitempty"`
+}
+
+// RemoveTracks removes tracks from a playlist, at most MaxTracksPerRemoval of them, returning
+// the new snapshot ID of the playlist. A non-empty snapshotID applies the removal to that
+// version of the playlist, which matters when Positions are given.
+func (c *Client) RemoveTracks(ctx context.Context, playlistID string, tracks []TrackToRemove, snapshotID string) (string, error) {
+	var response struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	err := c.do(ctx, "DELETE", "/playlists/"+playlistID+"/tracks", nil, removeTracksRequest{Tracks: tracks, SnapshotID: snapshotID}, &response)
+	return response.SnapshotID, err
+}
@@ -0,0 +1,72 @@
+package spotify
+
+import "time"
+
+// Page is one page of a paginated endpoint
+type Page[T any] struct {
+	Items  []T    `json:"items"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Total  int    `json:"total"`
+	Next   string `json:"next"`
+}
+
+// User is the profile of a Spotify user
+type User struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	// Only returned when the token was granted user-read-private
+	Country string `json:"country"`
+}
+
+type Track struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Artists     []Artist    `json:"artists"`
+	Explicit    bool        `json:"explicit"`
+	DurationMs  int         `json:"duration_ms"`
+	ExternalIDs ExternalIDs `json:"external_ids"`
+	// From 0 to 100, based on recent plays
+	Popularity int `json:"popularity,omitempty"`
+}
+
+// ExternalIDs identify a track outside Spotify
+type ExternalIDs struct {
+	ISRC string `json:"isrc,omitempty"`
+}
+
+func (t Track) Duration() time.Duration {
+	return time.Duration(t.DurationMs) * time.Millisecond
+}
+
+type Artist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Only returned when the artist is fetched on its own, not within a track
+	Genres []string `json:"genres,omitempty"`
+}
+
+// SavedTrack is a track in the user's liked songs
+type SavedTrack struct {
+	AddedAt time.Time `json:"added_at"`
+	Track   Track     `json:"track"`
+}
+
+// PlaylistTrack is an item of a playlist
+type PlaylistTrack struct {
+	AddedAt time.Time `json:"added_at"`
+	// Only the ID of the user is filled in
+	AddedBy User  `json:"added_by"`
+	Track   Track `json:"track"`
+}
+
+// Playlist is a playlist as listed in the user's library; the fields the client reads of a
+// single playlist are filled in the same struct
+type Playlist struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	// Whether other users can add tracks, which Spotify only allows on private playlists
+	Collaborative bool `json:"collaborative"`
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State persisted between runs
+type syncState struct {
+	// Time, as seen by the sync clock, of the last successful sync
+	LastSyncedAt time.Time `json:"last_synced_at"`
+	// Size of the liked library and time of the newest like at the last successful sync
+	LibraryTotal  int       `json:"library_total"`
+	NewestAddedAt time.Time `json:"newest_added_at"`
+	// Time of the newest like the watch mode has added
+	WatchCursor time.Time `json:"watch_cursor,omitempty"`
+	// IDs of the playlists managed by the tool, keyed by playlist name
+	Playlists map[string]string `json:"playlists,omitempty"`
+	// Provenance of the added tracks that didn't come from the liked songs, keyed by track ID
+	Provenance map[string]string `json:"provenance,omitempty"`
+	// IDs of the liked songs the tool added, keyed by playlist ID: the only tracks --prune
+	// removes, so the ones added by hand or by collaborators stay
+	Added map[string][]string `json:"added,omitempty"`
+}
+
+// Function to get the default state file location, in the user's config directory
+func defaultStatePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".spotify-like-songs-state.json"
+	}
+	return filepath.Join(dir, "spotify-like-songs", "state.json")
+}
+
+// Function to load the state file, returning an empty state when it doesn't exist yet
+func loadState(path string) (syncState, error) {
+	var state syncState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+func saveState(path string, state syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileWithBackups(path, append(data, '\n'), 0o600)
+}
+
+// Function to record a successful sync, never moving the last sync back in time
+func (s *syncState) markSynced(at time.Time) {
+	if at.After(s.LastSyncedAt) {
+		s.LastSyncedAt = at
+	}
+}
+
+func (s *syncState) setPlaylist(name, playlistID string) {
+	if s.Playlists == nil {
+		s.Playlists = map[string]string{}
+	}
+	s.Playlists[name] = playlistID
+}
+
+func (s *syncState) setProvenance(trackID, source string) {
+	if s.Provenance == nil {
+		s.Provenance = map[string]string{}
+	}
+	s.Provenance[trackID] = source
+}
+
+// Function to record liked songs the tool added to a playlist
+func (s *syncState) recordAdded(playlistID string, tracks []Track) {
+	if len(tracks) == 0 {
+		return
+	}
+	if s.Added == nil {
+		s.Added = map[string][]string{}
+	}
+	known := s.addedTo(playlistID)
+	for _, track := range tracks {
+		if !known[track.ID] {
+			known[track.ID] = true
+			s.Added[playlistID] = append(s.Added[playlistID], track.ID)
+		}
+	}
+}
+
+// Function to get the set of the liked songs the tool added to a playlist
+func (s syncState) addedTo(playlistID string) map[string]bool {
+	added := make(map[string]bool, len(s.Added[playlistID]))
+	for _, id := range s.Added[playlistID] {
+		added[id] = true
+	}
+	return added
+}
+
+// Function to forget tracks removed from a playlist
+func (s *syncState) forgetAdded(playlistID string, tracks []Track) {
+	removed := make(map[string]bool, len(tracks))
+	for _, track := range tracks {
+		removed[track.ID] = true
+	}
+	kept := s.Added[playlistID][:0]
+	for _, id := range s.Added[playlistID] {
+		if !removed[id] {
+			kept = append(kept, id)
+		}
+	}
+	s.Added[playlistID] = kept
+}
+
+// Function to tell whether the liked library looks the same as at the last successful sync
+func (s syncState) unchanged(total int, newest time.Time) bool {
+	return !s.LastSyncedAt.IsZero() && s.LibraryTotal == total && s.NewestAddedAt.Equal(newest)
+}
+
+// Function to list the ends of the past periods (months by default) that were never synced after
+// they ended, so their last likes are picked up the next time the tool runs (anacron-style)
+func (s syncState) missedMonths(now time.Time) []time.Time {
+	if s.LastSyncedAt.IsZero() {
+		return nil
+	}
+
+	var missed []time.Time
+	current := periodStart(now)
+	for period := periodStart(s.LastSyncedAt.In(now.Location())); period.Before(current); period = nextPeriod(period) {
+		if end := periodEnd(period); s.LastSyncedAt.Before(end) {
+			missed = append(missed, end)
+		}
+	}
+	return missed
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// Function to manage the local state file
+func runState(ctx context.Context, args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: spotify-cli state show|verify|repair|gc [flags]")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("state "+args[0], flag.ExitOnError)
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping the state between runs")
+
+	switch args[0] {
+	case "show":
+		format := flags.String("format", "table", "output format: "+formatNames())
+		tmpl := flags.String("template", "", "Go template used by --format template")
+		flags.Parse(args[1:])
+		state, err := loadState(*statePath)
+		if err != nil {
+			fmt.Println("Error loading state:", err)
+			return
+		}
+		if err := writeReport(os.Stdout, *format, *tmpl, stateReport(state)); err != nil {
+			fmt.Println("Error writing state:", err)
+		}
+	case "verify":
+		flags.Parse(args[1:])
+		problems, err := verifyState(ctx, *statePath)
+		if err != nil {
+			fmt.Println("Error verifying state:", err)
+			return
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			fmt.Printf("Found %d problem(s); run state repair to fix them.\n", len(problems))
+			os.Exit(1)
+		}
+		fmt.Println("The state matches Spotify.")
+	case "repair":
+		historyPath := flags.String("history-file", defaultHistoryPath(), "run history to rebuild the state from")
+		flags.Parse(args[1:])
+		if err := repairState(ctx, *statePath, *historyPath); err != nil {
+			fmt.Println("Error repairing state:", err)
+		}
+	case "gc":
+		cachePath := flags.String("cache-file", defaultCachePath(), "local cache of track metadata")
+		retention := flags.Duration("retention", 90*24*time.Hour, "how long tracks that are no longer liked stay in the cache")
+		flags.Parse(args[1:])
+		if err := collectGarbage(ctx, *statePath, *cachePath, *retention); err != nil {
+			fmt.Println("Error collecting garbage:", err)
+		}
+	default:
+		fmt.Printf("Unknown state command %q; available: show, verify, repair, gc\n", args[0])
+		os.Exit(2)
+	}
+}
+
+func stateReport(state syncState) report {
+	rows := [][]string{
+		{"Last synced at", formatStateTime(state.LastSyncedAt)},
+		{"Library size", fmt.Sprint(state.LibraryTotal)},
+		{"Newest like", formatStateTime(state.NewestAddedAt)},
+	}
+	for _, name := range playlistNames(state) {
+		rows = append(rows, []string{"Playlist " + name, state.Playlists[name]})
+	}
+	return report{Columns: []string{"FIELD", "VALUE"}, Rows: rows, Data: state}
+}
+
+func formatStateTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Function to list the names of the playlists in the state, sorted
+func playlistNames(state syncState) []string {
+	names := make([]string, 0, len(state.Playlists))
+	for name := range state.Playlists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Function to cross-check the state against Spotify, listing what doesn't match: playlists that
+// were deleted or renamed and sync times in the future
+func verifyState(ctx context.Context, statePath string) ([]string, error) {
+	state, err := loadState(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("loading state: %w", err)
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+
+	var problems []string
+	now := time.Now()
+	if state.LastSyncedAt.After(now) {
+		problems = append(problems, fmt.Sprintf("The last sync, at %s, is in the future.", state.LastSyncedAt.Format(time.RFC3339)))
+	}
+	if state.NewestAddedAt.After(now) {
+		problems = append(problems, fmt.Sprintf("The newest like, at %s, is in the future.", state.NewestAddedAt.Format(time.RFC3339)))
+	}
+	for _, name := range playlistNames(state) {
+		playlistID := state.Playlists[name]
+		playlist, err := apiClient(token.AccessToken).Playlist(ctx, playlistID, "id,name")
+		switch {
+		case isNotFound(err):
+			problems = append(problems, fmt.Sprintf("Playlist %s (%s) was deleted in Spotify.", name, playlistID))
+		case err != nil:
+			return nil, fmt.Errorf("checking playlist %s: %w", name, err)
+		case !playlistNamesMatch(playlist.Name, name):
+			problems = append(problems, fmt.Sprintf("Playlist %s (%s) is named %s in Spotify.", name, playlistID, playlist.Name))
+		}
+	}
+	return problems, nil
+}
+
+// Function to rebuild the state from the run history and Spotify: the playlists the runs synced
+// that still exist under the same name, and the time of the last successful run. The library
+// snapshot is left empty so the next sync reads the liked songs instead of skipping.
+// State that can't be read is rebuilt from scratch. With --dry-run nothing is saved.
+func repairState(ctx context.Context, statePath, historyPath string) error {
+	old, err := loadState(statePath)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not read the state, rebuilding it from scratch", "path", statePath, "error", err)
+	}
+	history, err := loadRunHistory(historyPath)
+	if err != nil {
+		return fmt.Errorf("loading run history: %w", err)
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+
+	candidates := map[string]string{}
+	for name, playlistID := range old.Playlists {
+		candidates[name] = playlistID
+	}
+	var repaired syncState
+	for _, run := range history {
+		if run.Error == "" && run.StartedAt.Before(time.Now()) {
+			repaired.markSynced(run.StartedAt)
+		}
+		for _, playlist := range run.Playlists {
+			if playlist.ID != "" {
+				candidates[playlist.Name] = playlist.ID
+			}
+		}
+	}
+
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		playlist, err := apiClient(token.AccessToken).Playlist(ctx, candidates[name], "id,name")
+		switch {
+		case isNotFound(err):
+			slog.InfoContext(ctx, "Leaving out a playlist deleted in Spotify", "playlist", name, "playlist_id", candidates[name])
+		case err != nil:
+			return fmt.Errorf("checking playlist %s: %w", name, err)
+		case !playlistNamesMatch(playlist.Name, name):
+			slog.InfoContext(ctx, "Leaving out a renamed playlist", "playlist", name, "playlist_id", candidates[name], "name", playlist.Name)
+		default:
+			repaired.setPlaylist(name, playlist.ID)
+		}
+	}
+
+	if dryRun {
+		fmt.Println("Dry run: would save this state:")
+		return writeReport(os.Stdout, "table", "", stateReport(repaired))
+	}
+	if err := saveState(statePath, repaired); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	fmt.Printf("Rebuilt the state with %d playlist(s), last synced %s.\n", len(repaired.Playlists), formatStateTime(repaired.LastSyncedAt))
+	return nil
+}
+
+// Function to drop the state entries of playlists deleted in Spotify and the cached tracks that
+// are no longer liked and were cached longer than retention ago. With --dry-run nothing is saved.
+func collectGarbage(ctx context.Context, statePath, cachePath string, retention time.Duration) error {
+	state, err := loadState(statePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	cache, err := loadTrackCache(cachePath, defaultCacheTTL)
+	if err != nil {
+		return fmt.Errorf("loading cache: %w", err)
+	}
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+
+	var deleted []string
+	for _, name := range playlistNames(state) {
+		exists, err := playlistExists(ctx, token.AccessToken, state.Playlists[name])
+		if err != nil {
+			return fmt.Errorf("checking playlist %s: %w", name, err)
+		}
+		if !exists {
+			deleted = append(deleted, name)
+		}
+	}
+
+	liked, err := fetchLikedSongs(ctx, token.AccessToken, time.Time{})
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	likedIDs := make(map[string]bool, len(liked.Items))
+	for _, song := range liked.Items {
+		likedIDs[song.Track.ID] = true
+	}
+	var unliked []string
+	for id, entry := range cache.Entries {
+		if !likedIDs[id] && time.Since(entry.CachedAt) > retention {
+			unliked = append(unliked, id)
+		}
+	}
+
+	for _, name := range deleted {
+		fmt.Printf("Playlist %s (%s) was deleted in Spotify.\n", name, state.Playlists[name])
+	}
+	if dryRun {
+		fmt.Printf("Dry run: would remove %d deleted playlist(s) from the state and %d unliked track(s) from the cache.\n", len(deleted), len(unliked))
+		return nil
+	}
+
+	for _, name := range deleted {
+		delete(state.Playlists, name)
+	}
+	for _, id := range unliked {
+		delete(cache.Entries, id)
+	}
+	if len(deleted) > 0 {
+		if err := saveState(statePath, state); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+	}
+	if len(unliked) > 0 {
+		if err := cache.save(); err != nil {
+			return fmt.Errorf("saving cache: %w", err)
+		}
+	}
+	fmt.Printf("Removed %d deleted playlist(s) from the state and %d unliked track(s) from the cache.\n", len(deleted), len(unliked))
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Everything status reports about the authenticated account and the local installation
+type statusInfo struct {
+	UserID         string     `json:"user_id"`
+	DisplayName    string     `json:"display_name"`
+	App            string     `json:"app"`
+	TokenCached    bool       `json:"token_cached"`
+	TokenExpiresAt time.Time  `json:"token_expires_at"`
+	GrantedScopes  []string   `json:"granted_scopes"`
+	MissingScopes  []string   `json:"missing_scopes"`
+	EnvFile        string     `json:"env_file"`
+	ConfigFile     string     `json:"config_file"`
+	ConfigMissing  bool       `json:"config_missing,omitempty"`
+	StateFile      string     `json:"state_file"`
+	LastSyncedAt   time.Time  `json:"last_synced_at"`
+	LastRun        *statusRun `json:"last_run,omitempty"`
+	LockFile       string     `json:"lock_file,omitempty"`
+	LockHeldSince  time.Time  `json:"lock_held_since"`
+}
+
+// Outcome of the last run of the history
+type statusRun struct {
+	FinishedAt  time.Time `json:"finished_at"`
+	TracksAdded int       `json:"tracks_added"`
+	Partial     bool      `json:"partial,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Function to show which account is authenticated and the state of its token
+func runStatus(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	lockPath := flags.String("lock-file", "", "lock file used by sync, reported when set")
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping track of past syncs")
+	historyPath := flags.String("history-file", defaultHistoryPath(), "file recording every run, whose last one is reported")
+	format := flags.String("format", "table", "output format: "+formatNames())
+	tmpl := flags.String("template", "", "Go template used by --format template")
+	fields := flags.String("fields", "", "comma-separated fields to include, by JSON path (e.g. user_id,token_expires_at)")
+	flags.Parse(args)
+
+	if err := checkFieldsFormat(*fields, *format); err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	requestedAt := time.Now()
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		fmt.Println("Not authenticated:", err)
+		return
+	}
+	defer apps.release()
+
+	profile, err := getCurrentUser(ctx, token.AccessToken)
+	if err != nil {
+		fmt.Println("Error getting current user:", explainError(err))
+		return
+	}
+
+	required := requiredScopes(syncFeatures()...)
+	app, _, cached := apps.activeToken()
+	info := statusInfo{
+		UserID:      profile.ID,
+		DisplayName: profile.DisplayName,
+		App:         app.Name,
+		TokenCached: cached,
+		// A cached token's expires_in is what's left of it
+		TokenExpiresAt: requestedAt.Add(time.Duration(token.ExpiresIn) * time.Second),
+		GrantedScopes:  strings.Fields(token.Scope),
+		MissingScopes:  missingScopes(token.Scope, required),
+		EnvFile:        envFile,
+		ConfigFile:     configFile,
+		StateFile:      *statePath,
+	}
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		info.ConfigMissing = true
+	}
+
+	state, err := loadState(*statePath)
+	if err != nil {
+		fmt.Println("Error loading state:", err)
+		return
+	}
+	info.LastSyncedAt = state.LastSyncedAt
+
+	if *historyPath != "" {
+		history, err := loadRunHistory(*historyPath)
+		if err != nil {
+			fmt.Println("Error loading run history:", err)
+			return
+		}
+		if len(history) > 0 {
+			last := history[len(history)-1]
+			info.LastRun = &statusRun{FinishedAt: last.FinishedAt, TracksAdded: last.TracksAdded, Partial: last.Partial, Error: last.Error}
+		}
+	}
+
+	if *lockPath != "" {
+		info.LockFile = *lockPath
+		if stat, err := os.Stat(*lockPath); err == nil {
+			info.LockHeldSince = stat.ModTime()
+		}
+	}
+
+	r, err := selectFields(info.report(), *fields)
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if err := writeReport(os.Stdout, *format, *tmpl, r); err != nil {
+		fmt.Println("Error writing status:", err)
+	}
+}
+
+func (s statusInfo) report() report {
+	lastSynced := "never"
+	if !s.LastSyncedAt.IsZero() {
+		lastSynced = s.LastSyncedAt.Format(time.RFC3339)
+	}
+
+	rows := [][]string{
+		{"Account", fmt.Sprintf("%s (%s)", s.DisplayName, s.UserID)},
+		{"Spotify app", s.App},
+		{"Access token", s.tokenFreshness(time.Now())},
+		{"Granted scopes", strings.Join(s.GrantedScopes, ", ")},
+	}
+	if len(s.MissingScopes) > 0 {
+		rows = append(rows, []string{"Missing scopes", strings.Join(s.MissingScopes, ", ")})
+	}
+	config := s.ConfigFile
+	if s.ConfigMissing {
+		config += " (missing, defaults apply)"
+	}
+	rows = append(rows, []string{"Env file", s.EnvFile}, []string{"Config file", config})
+	rows = append(rows, []string{"State file", fmt.Sprintf("%s (last synced %s)", s.StateFile, lastSynced)})
+	if run := s.LastRun; run != nil {
+		outcome := fmt.Sprintf("succeeded, %d track(s) added", run.TracksAdded)
+		switch {
+		case run.Error != "":
+			outcome = "failed: " + run.Error
+		case run.Partial:
+			outcome = fmt.Sprintf("stopped at the API budget, %d track(s) added", run.TracksAdded)
+		}
+		rows = append(rows, []string{"Last run", fmt.Sprintf("%s at %s", outcome, run.FinishedAt.Format(time.RFC3339))})
+	}
+	if s.LockFile != "" {
+		lock := "free"
+		if !s.LockHeldSince.IsZero() {
+			lock = "held since " + s.LockHeldSince.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{"Lock file", fmt.Sprintf("%s (%s)", s.LockFile, lock)})
+	}
+	return report{Columns: []string{"FIELD", "VALUE"}, Rows: rows, Data: s}
+}
+
+// Function to describe the access token: cached by an earlier run or refreshed by this one, and
+// whether it's about to expire, when the next run refreshes it
+func (s statusInfo) tokenFreshness(now time.Time) string {
+	source := "refreshed for this run"
+	if s.TokenCached {
+		source = "cached"
+	}
+	remaining := s.TokenExpiresAt.Sub(now).Round(time.Minute)
+	freshness := fmt.Sprintf("fresh for %s", remaining)
+	if remaining <= tokenRefreshMargin {
+		freshness = "about to expire"
+	}
+	return fmt.Sprintf("%s, %s, expires at %s", source, freshness, s.TokenExpiresAt.Format(time.RFC3339))
+}
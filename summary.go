@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+	colorBold   = "\033[1m"
+)
+
+// Function to tell whether the summary should be colorized: only on a terminal and when NO_COLOR is unset
+func useColor(file *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Function to print the end-of-run summary of a synced month, with a status line per track
+func printSummary(w io.Writer, result syncResult, syncErr error, color bool) {
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + colorReset
+	}
+
+	added := map[string]bool{}
+	for _, track := range result.Added {
+		added[track.ID] = true
+	}
+	failed := map[string]bool{}
+	for _, track := range result.Failed {
+		failed[track.ID] = true
+	}
+
+	fmt.Fprintf(w, "\n%s\n", paint(colorBold, result.PlaylistName))
+	for _, track := range result.Kept {
+		switch {
+		case added[track.ID] && result.source(track) != provenanceLikedSong:
+			fmt.Fprintf(w, "  ✅ %s - %s %s\n", track.Name, artistNames(track), paint(colorYellow, "(from "+result.source(track)+")"))
+		case added[track.ID]:
+			fmt.Fprintf(w, "  ✅ %s - %s\n", track.Name, artistNames(track))
+		case failed[track.ID]:
+			fmt.Fprintf(w, "  ❌ %s - %s %s\n", track.Name, artistNames(track), paint(colorRed, "(missing after retry)"))
+		case syncErr != nil:
+			fmt.Fprintf(w, "  ❌ %s - %s %s\n", track.Name, artistNames(track), paint(colorRed, "(not added)"))
+		default:
+			fmt.Fprintf(w, "  ⏭️  %s - %s %s\n", track.Name, artistNames(track), paint(colorYellow, "(already in playlist)"))
+		}
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Fprintf(w, "  ⏭️  %s - %s %s\n", skipped.Track.Name, artistNames(skipped.Track), paint(colorYellow, "("+skipped.Reason+")"))
+	}
+	for _, track := range result.Removed {
+		fmt.Fprintf(w, "  🗑️  %s - %s %s\n", track.Name, artistNames(track), paint(colorYellow, "(no longer liked)"))
+	}
+
+	fmt.Fprintf(w, "%s liked, %s added, %s skipped",
+		paint(colorBold, fmt.Sprint(len(result.Liked))),
+		paint(colorGreen, fmt.Sprint(len(result.Added))),
+		paint(colorYellow, fmt.Sprint(len(result.Liked)-len(result.Added)-len(result.Failed))))
+	if len(result.Failed) > 0 {
+		fmt.Fprintf(w, ", %s failed", paint(colorRed, fmt.Sprint(len(result.Failed))))
+	}
+	if len(result.Removed) > 0 {
+		fmt.Fprintf(w, ", %s removed", paint(colorYellow, fmt.Sprint(len(result.Removed))))
+	}
+	fmt.Fprintln(w)
+	if syncErr != nil {
+		fmt.Fprintln(w, paint(colorRed, "Error "+syncErr.Error()))
+	}
+	if result.PlaylistID != "" {
+		fmt.Fprintln(w, playlistURL(result.PlaylistID))
+	}
+}
+
+// Function to write the JSON summary of a run for automation, to stdout with "-"
+func writeRunSummary(path string, summary runSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
@@ -0,0 +1,790 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Options of a sync run
+type syncOptions struct {
+	Clock          Clock
+	Filters        trackFilters
+	CollectSkipped bool
+	LockPath       string
+	LockTTL        time.Duration
+	StatePath      string
+	HistoryPath    string
+	ShortlistPath  string
+	// File receiving the JSON summary of every run, "-" for stdout
+	OutputPath        string
+	Pretty            bool
+	SkipUnchanged     bool
+	Description       string
+	DescriptionPolicy string
+	// Add new tracks at the top of the playlist instead of the bottom
+	Prepend bool
+	// Remove the tracks of the playlist that are no longer in the filtered liked set
+	Prune bool
+	// Only read from Spotify, printing the playlists and tracks a real run would create and add
+	DryRun bool
+	// What to do when a playlist recorded in the state was deleted: recreate or abort
+	OnDeletedPlaylist string
+	Plugins           []*plugin
+	// Provenance labels of the sources whose tracks come first and win duplicates, and how
+	// duplicates are recognized: by track ID or ISRC
+	SourcePriority []string
+	SourceDedupe   string
+	Cache          *trackCache
+	Notifiers      []notifier
+	Hooks          syncHooks
+}
+
+// Outcome of syncing one month
+type syncResult struct {
+	PlaylistName string
+	PlaylistID   string
+	Liked        []Track
+	Kept         []Track
+	Added        []Track
+	Skipped      []skippedTrack
+	// Tracks removed by --prune
+	Removed []Track
+	// Tracks reported as added that were still missing from the playlist after a retry
+	Failed []Track
+	// Provenance of the tracks not coming from the liked songs, keyed by track ID
+	Sources map[string]string
+}
+
+// Provenance of the tracks coming from the liked songs
+const provenanceLikedSong = "liked-song"
+
+// Function to get which source brought a track into the sync
+func (r syncResult) source(track Track) string {
+	if source, ok := r.Sources[track.ID]; ok {
+		return source
+	}
+	return provenanceLikedSong
+}
+
+// Flags shared by every subcommand that runs syncs
+type syncFlags struct {
+	shortlistPath     *string
+	outputPath        *string
+	skipExplicit      *bool
+	maxDuration       *time.Duration
+	excludeArtists    *string
+	skipIf            *string
+	lockPath          *string
+	lockTTL           *time.Duration
+	collectSkipped    *bool
+	asOf              *string
+	statePath         *string
+	historyPath       *string
+	pretty            *bool
+	position          *string
+	description       *string
+	descriptionPolicy *string
+	onDeleted         *string
+	skipUnchanged     *bool
+	prune             *bool
+	cachePath         *string
+	cacheTTL          *time.Duration
+	sourcePriority    *string
+	sourceDedupe      *string
+	hooks             syncHooks
+	filterRules       stringList
+	pluginPaths       stringList
+	// Flag set the sync flags were registered in, and their names, for the config file
+	flags *flag.FlagSet
+	names map[string]bool
+}
+
+func registerSyncFlags(flags *flag.FlagSet) *syncFlags {
+	f := &syncFlags{flags: flags, names: map[string]bool{}}
+	existing := map[string]bool{}
+	flags.VisitAll(func(fl *flag.Flag) { existing[fl.Name] = true })
+	defer flags.VisitAll(func(fl *flag.Flag) {
+		if !existing[fl.Name] {
+			f.names[fl.Name] = true
+		}
+	})
+
+	f.shortlistPath = flags.String("shortlist", "", "write spotify: and open.spotify.com links for added tracks to this file (\"-\" for stdout)")
+	f.outputPath = flags.String("output", "", "write the JSON summary of the run, with the playlists and their track counts, to this file (\"-\" for stdout), dry runs included")
+	f.skipExplicit = flags.Bool("skip-explicit", false, "skip tracks marked as explicit")
+	f.maxDuration = flags.Duration("max-duration", 0, "skip tracks longer than this duration (e.g. 10m)")
+	f.excludeArtists = flags.String("exclude-artists", "", "comma-separated list of artists whose tracks are skipped")
+	f.skipIf = flags.String("skip-if", "", "expr-lang expression over track and banned (the excluded artists); matching tracks are skipped")
+	f.lockPath = flags.String("lock-file", "", "lock file (e.g. on shared storage) ensuring only one instance syncs at a time")
+	f.lockTTL = flags.Duration("lock-ttl", 30*time.Minute, "age after which a lock file is considered stale")
+	f.collectSkipped = flags.Bool("skipped-playlist", false, "collect tracks rejected by the filters into a companion private playlist")
+	f.asOf = flags.String("as-of", "", "run as if on this date (YYYY-MM-DD), e.g. to regenerate last month's playlist")
+	f.statePath = flags.String("state-file", defaultStatePath(), "file keeping track of past syncs")
+	f.historyPath = flags.String("history-file", defaultHistoryPath(), "file recording every run, used for the weekly digest (empty to disable)")
+	f.pretty = flags.Bool("pretty", false, "print a summary with a status line per track at the end of the run")
+	f.position = flags.String("position", positionAppend, "where new tracks go in the playlist: append (bottom) or prepend (top)")
+	f.description = flags.String("description", "Monthly Playlist", "description of the monthly playlists")
+	f.descriptionPolicy = flags.String("description-policy", descriptionNever, "how existing playlist descriptions are updated: never, replace or append (a dated line)")
+	f.onDeleted = flags.String("on-deleted-playlist", deletedPlaylistRecreate, "what to do when a managed playlist was deleted in Spotify: recreate or abort")
+	f.prune = flags.Bool("prune", false, "remove the liked songs the tool added to the playlist that are no longer liked or now rejected by the filters; tracks added by hand stay")
+	f.skipUnchanged = flags.Bool("skip-unchanged", false, "skip the sync when the liked library hasn't changed since the last one")
+	f.cachePath = flags.String("cache-file", defaultCachePath(), "local cache of track metadata (empty to disable)")
+	f.cacheTTL = flags.Duration("cache-ttl", defaultCacheTTL, "how long cached track metadata is used")
+	f.sourcePriority = flags.String("source-priority", provenanceLikedSong, "comma-separated provenance labels of the sources whose tracks come first and win duplicates")
+	f.sourceDedupe = flags.String("source-dedupe", dedupeByID, "how tracks from several sources are recognized as the same: id or isrc (any release of the recording)")
+	flags.StringVar(&f.hooks.PreSync, "pre-sync", "", "command run before the sync; the sync is aborted when it fails")
+	flags.StringVar(&f.hooks.PostSync, "post-sync", "", "command run after a successful sync")
+	flags.StringVar(&f.hooks.OnError, "on-error", "", "command run when the sync fails")
+	flags.Var(&f.filterRules, "filter", "expr-lang rule every kept track must satisfy, e.g. 'track.DurationMs > 90000 && !track.Explicit' (repeatable)")
+	flags.Var(&f.pluginPaths, "plugin", "external plugin executable providing sources, filters or sinks (repeatable)")
+	return f
+}
+
+// Function to validate the parsed flags and build the sync options from them, the ones not
+// given on the command line coming from the config file or the environment
+func (f *syncFlags) options() (syncOptions, error) {
+	unknown, errs := applySettings(f.flags, syncSettings(), explicitFlags(f.flags), f.names)
+	if len(errs) > 0 {
+		return syncOptions{}, fmt.Errorf("applying the config file: %w", errs[0])
+	}
+	for _, key := range unknown {
+		slog.Warn("Ignoring an unknown sync setting of the config file", "setting", key, "path", configFile)
+	}
+	clock, err := parseAsOf(*f.asOf)
+	if err != nil {
+		return syncOptions{}, fmt.Errorf("parsing --as-of: %w", err)
+	}
+
+	skipIfProgram, err := compileFilterExpr(*f.skipIf)
+	if err != nil {
+		return syncOptions{}, fmt.Errorf("compiling --skip-if: %w", err)
+	}
+
+	rules, err := compileFilterRules(f.filterRules)
+	if err != nil {
+		return syncOptions{}, fmt.Errorf("compiling --filter: %w", err)
+	}
+
+	if *f.descriptionPolicy != descriptionNever && *f.descriptionPolicy != descriptionReplace && *f.descriptionPolicy != descriptionAppend {
+		return syncOptions{}, fmt.Errorf("parsing --description-policy: must be %s, %s or %s", descriptionNever, descriptionReplace, descriptionAppend)
+	}
+	if *f.position != positionAppend && *f.position != positionPrepend {
+		return syncOptions{}, fmt.Errorf("parsing --position: must be %s or %s", positionAppend, positionPrepend)
+	}
+	if *f.onDeleted != deletedPlaylistRecreate && *f.onDeleted != deletedPlaylistAbort {
+		return syncOptions{}, fmt.Errorf("parsing --on-deleted-playlist: must be %s or %s", deletedPlaylistRecreate, deletedPlaylistAbort)
+	}
+	if *f.sourceDedupe != dedupeByID && *f.sourceDedupe != dedupeByISRC {
+		return syncOptions{}, fmt.Errorf("parsing --source-dedupe: must be %s or %s", dedupeByID, dedupeByISRC)
+	}
+
+	plugins, err := loadPlugins(f.pluginPaths)
+	if err != nil {
+		return syncOptions{}, fmt.Errorf("loading plugins: %w", err)
+	}
+
+	notifiers, err := loadNotifiers()
+	if err != nil {
+		return syncOptions{}, fmt.Errorf("loading notifiers: %w", err)
+	}
+
+	cache, err := loadTrackCache(*f.cachePath, *f.cacheTTL)
+	if err != nil {
+		return syncOptions{}, fmt.Errorf("loading track cache: %w", err)
+	}
+
+	return syncOptions{
+		Clock:          clock,
+		Plugins:        plugins,
+		SourcePriority: parseList(*f.sourcePriority),
+		SourceDedupe:   *f.sourceDedupe,
+		Cache:          cache,
+		Notifiers:      notifiers,
+		Hooks:          f.hooks,
+		Filters: trackFilters{
+			SkipExplicit:    *f.skipExplicit,
+			MaxDuration:     *f.maxDuration,
+			ExcludedArtists: parseList(*f.excludeArtists),
+			SkipIf:          skipIfProgram,
+			Rules:           rules,
+		},
+		CollectSkipped:    *f.collectSkipped,
+		LockPath:          *f.lockPath,
+		LockTTL:           *f.lockTTL,
+		StatePath:         *f.statePath,
+		HistoryPath:       *f.historyPath,
+		ShortlistPath:     *f.shortlistPath,
+		OutputPath:        *f.outputPath,
+		Pretty:            *f.pretty,
+		SkipUnchanged:     *f.skipUnchanged,
+		OnDeletedPlaylist: *f.onDeleted,
+		Description:       *f.description,
+		DescriptionPolicy: *f.descriptionPolicy,
+		Prepend:           *f.position == positionPrepend,
+		Prune:             *f.prune,
+		DryRun:            dryRun,
+	}, nil
+}
+
+// Function to sync the liked songs of the current month into the monthly playlist
+func runSync(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	monthFlag := flags.String("month", "", "sync this month (YYYY-MM), or the period holding this day (YYYY-MM-DD), instead of the current one")
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+
+	if *monthFlag != "" {
+		if *sf.asOf != "" {
+			fmt.Println("Error: --month and --as-of can't be used together")
+			return
+		}
+		month, err := parsePeriodArg(*monthFlag)
+		if err != nil {
+			fmt.Println("Error parsing --month:", err)
+			return
+		}
+		current := periodStart(nowInMonthZone())
+		if month.After(current) {
+			fmt.Println("Error: --month is in the future")
+			return
+		}
+		if month.Before(current) {
+			// A past period is synced from the whole library, like resync does
+			perform := func(ctx context.Context, opts syncOptions, summary *runSummary) error {
+				return performBackfill(ctx, opts, month, month, summary)
+			}
+			if _, err := runWithHooks(ctx, opts, perform); err != nil {
+				fmt.Println("Error", err)
+			}
+			return
+		}
+	}
+
+	if _, err := runSyncWithHooks(ctx, opts); err != nil {
+		fmt.Println("Error", err)
+	}
+}
+
+// Function to run a sync between its pre_sync and post_sync/on_error hooks
+func runSyncWithHooks(ctx context.Context, opts syncOptions) (runSummary, error) {
+	return runWithHooks(ctx, opts, performSync)
+}
+
+// Function to run a sync-like operation between the hooks, notifying and recording its outcome
+func runWithHooks(ctx context.Context, opts syncOptions, perform func(context.Context, syncOptions, *runSummary) error) (runSummary, error) {
+	summary := runSummary{RunID: nextRunID(), StartedAt: time.Now()}
+	if t, ok := tenantFromContext(ctx); ok {
+		summary.Tenant = t.ID
+	}
+	// Every run, whichever command or server starts it, gets the whole API call budget
+	ctx = withAPIBudget(withCorrelationID(ctx, summary.RunID))
+	if summary.RunID != processID {
+		slog.InfoContext(ctx, "Starting a run")
+	}
+	if opts.DryRun {
+		// Hooks and notifiers may act on the outcome, so a dry run leaves them out, only
+		// writing the summary for the scripts reading it
+		summary.DryRun = true
+		err := explainError(perform(ctx, opts, &summary))
+		summary.FinishedAt = time.Now()
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		writeOutput(ctx, opts.OutputPath, summary)
+		return summary, err
+	}
+	if err := runHook(ctx, "pre_sync", opts.Hooks.PreSync, summary); err != nil {
+		return summary, fmt.Errorf("running pre_sync hook: %w", err)
+	}
+
+	err := explainError(perform(ctx, opts, &summary))
+	if errors.Is(err, errAPIBudget) {
+		// The state is saved after every period, so the next run picks up where this one stopped
+		slog.WarnContext(ctx, "Stopping; the progress so far is saved, run again to continue", "error", err)
+		summary.Partial, err = true, nil
+	}
+	summary.FinishedAt = time.Now()
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	sendNotification(ctx, opts.Notifiers, syncNotification(summary, err))
+	recordRun(ctx, opts.HistoryPath, opts.Notifiers, summary)
+	recordSyncMetrics(ctx, summary, err)
+	writeOutput(ctx, opts.OutputPath, summary)
+	if err != nil {
+		if err := runHook(ctx, "on_error", opts.Hooks.OnError, summary); err != nil {
+			slog.WarnContext(ctx, "The on_error hook failed", "error", err)
+		}
+		return summary, err
+	}
+
+	if err := runHook(ctx, "post_sync", opts.Hooks.PostSync, summary); err != nil {
+		slog.WarnContext(ctx, "The post_sync hook failed", "error", err)
+	}
+	return summary, nil
+}
+
+// Function to write the summary of a run to the --output file, when one is given
+func writeOutput(ctx context.Context, path string, summary runSummary) {
+	if path == "" {
+		return
+	}
+	if err := writeRunSummary(path, summary); err != nil {
+		slog.WarnContext(ctx, "Could not write the run summary", "path", path, "error", err)
+	}
+}
+
+// Function to run a sync, recording the outcome of each month in the summary
+func performSync(ctx context.Context, opts syncOptions, summary *runSummary) error {
+	// Make sure no other instance is syncing at the same time
+	if opts.LockPath != "" {
+		lock, err := acquireLock(opts.LockPath, opts.LockTTL)
+		if err != nil {
+			return fmt.Errorf("acquiring lock: %w", err)
+		}
+		defer lock.Release()
+	}
+
+	state, err := loadState(opts.StatePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	// Get access token
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	defer saveTrackCache(opts.Cache)
+	accessToken := token.AccessToken
+
+	// Look up who is syncing once, the playlists are created in their account
+	profile, err := currentProfile(ctx, accessToken, apps.active(), false)
+	if err != nil {
+		return fmt.Errorf("getting current user: %w", err)
+	}
+	summary.Owner = profileName(profile)
+	slog.InfoContext(ctx, "Syncing the liked songs", "user_id", profile.ID)
+
+	if err := checkSyncScopes(token); err != nil {
+		return err
+	}
+
+	// Check whether anything changed since the last sync, reading a single liked song
+	missed := state.missedMonths(opts.Clock.Now())
+	var total int
+	var newest time.Time
+	if opts.SkipUnchanged {
+		total, newest, err = probeLikedSongs(ctx, accessToken)
+		if err != nil {
+			return fmt.Errorf("probing liked songs: %w", err)
+		}
+		if len(missed) == 0 && state.unchanged(total, newest) {
+			slog.InfoContext(ctx, "The liked library hasn't changed; nothing to sync", "last_synced_at", state.LastSyncedAt.Format(time.RFC3339))
+			return nil
+		}
+	}
+
+	// Finish the past months that were not synced after they ended, then sync the current one
+	var clocks []Clock
+	for _, end := range missed {
+		slog.InfoContext(ctx, "Catching up on a period not synced after it ended", "playlist", periodPlaylistName(end))
+		clocks = append(clocks, fixedClock{t: end})
+	}
+	clocks = append(clocks, opts.Clock)
+
+	var addedSongs []Track
+	for i, periodClock := range clocks {
+		result, err := syncMonth(ctx, accessToken, periodClock, opts, &state)
+		summary.add(result)
+		if opts.Pretty {
+			printSummary(os.Stdout, result, err, useColor(os.Stdout))
+		}
+		if err != nil {
+			// Keep what the period got done, the playlist it created and the tracks it added,
+			// so the next run neither creates the playlist again nor loses track of them
+			if !opts.DryRun {
+				if err := saveState(opts.StatePath, state); err != nil {
+					slog.WarnContext(ctx, "Could not save the state", "path", opts.StatePath, "error", err)
+				}
+			}
+			return err
+		}
+		addedSongs = append(addedSongs, result.Added...)
+		if opts.DryRun {
+			continue
+		}
+		slog.InfoContext(ctx, "Synced the playlist", "playlist", result.PlaylistName, "playlist_id", result.PlaylistID, "tracks_added", len(result.Added))
+
+		// The missed months are synced as of their end, which closes them
+		if i < len(missed) {
+			finalizeMonth(ctx, accessToken, summary.Owner, opts.Cache, result, opts.Notifiers)
+		}
+
+		state.markSynced(periodClock.Now())
+		// The baseline of the next --skip-unchanged run, only known when the library was probed
+		if opts.SkipUnchanged {
+			state.LibraryTotal, state.NewestAddedAt = total, newest
+		}
+		if err := saveState(opts.StatePath, state); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+	}
+
+	// Write the shortlist of deep links for the added tracks, if requested
+	if opts.ShortlistPath != "" {
+		if err := writeShortlist(opts.ShortlistPath, addedSongs); err != nil {
+			return fmt.Errorf("writing shortlist: %w", err)
+		}
+	}
+	if opts.DryRun {
+		return nil
+	}
+	return runPluginSinks(ctx, opts.Plugins, *summary, addedSongs)
+}
+
+// Function to make sure the refresh token was granted every scope the sync features need
+func checkSyncScopes(token AccessTokenResponse) error {
+	return checkScopes(token, syncFeatures()...)
+}
+
+// Function to sync the liked songs of the clock's month into its monthly playlist
+func syncMonth(ctx context.Context, accessToken string, clock Clock, opts syncOptions, state *syncState) (syncResult, error) {
+	// Get the latest liked song
+	likedSongs, err := getLikedSongs(ctx, accessToken, clock)
+	if err != nil {
+		return syncResult{PlaylistName: periodPlaylistName(clock.Now())}, fmt.Errorf("getting liked songs: %w", err)
+	}
+	return syncTracks(ctx, accessToken, clock, likedSongs, opts, state)
+}
+
+// Function to sync the given liked songs into the monthly playlist of the clock's month
+func syncTracks(ctx context.Context, accessToken string, clock Clock, likedSongs []Track, opts syncOptions, state *syncState) (syncResult, error) {
+	// Get the current month and year for playlist naming
+	result := syncResult{PlaylistName: periodPlaylistName(clock.Now())}
+
+	// Add the tracks provided by the source plugins
+	pluginGroups, err := pluginSourceTracks(ctx, opts.Plugins, clock)
+	if err != nil {
+		return result, err
+	}
+	opts.Cache.put(likedSongs)
+	groups := []sourceTracks{{Source: provenanceLikedSong, Tracks: likedSongs}}
+	for _, group := range pluginGroups {
+		group.Tracks, err = enrichTracks(ctx, accessToken, opts.Cache, group.Tracks)
+		if err != nil {
+			return result, err
+		}
+		groups = append(groups, group)
+	}
+	result.Liked, result.Sources = mergeSources(groups, opts.SourcePriority, opts.SourceDedupe)
+
+	// Drop the songs rejected by the filters
+	result.Kept, result.Skipped = applyFilters(result.Liked, opts.Filters)
+	result.Kept, result.Skipped, err = applyPluginFilters(ctx, opts.Plugins, result.Kept, result.Skipped)
+	if err != nil {
+		return result, err
+	}
+
+	// Check if the playlist exists, creating it otherwise
+	name := result.PlaylistName
+	result.PlaylistName = playlistTitle(periodStart(clock.Now()), len(result.Kept))
+	result.PlaylistID, err = resolvePlaylist(ctx, accessToken, state, name, result.PlaylistName, opts.Description, opts.OnDeletedPlaylist, opts.DryRun)
+	if err != nil {
+		return result, fmt.Errorf("finding playlist: %w", err)
+	}
+	if opts.DryRun {
+		return planTracks(ctx, accessToken, name, result, opts, state)
+	}
+
+	// Add the liked song to the playlist, recording the batches added before a failure, e.g.
+	// once the API budget ran out
+	added, err := addSongToPlaylist(ctx, accessToken, result.PlaylistID, result.Kept, opts.Prepend)
+	if err != nil {
+		result.Added = added
+		recordAdditions(state, result)
+		return result, fmt.Errorf("adding song to playlist: %w", err)
+	}
+
+	// Make sure the added songs really are in the playlist, adding the missing ones once more
+	result.Added, result.Failed, err = verifyAdded(ctx, accessToken, result.PlaylistID, added, opts.Prepend)
+	if err != nil {
+		result.Added = added
+		recordAdditions(state, result)
+		return result, fmt.Errorf("verifying playlist: %w", err)
+	}
+	recordAdditions(state, result)
+
+	// Drop the tracks that left the liked set, if requested
+	if opts.Prune {
+		result.Removed, err = staleTracks(ctx, accessToken, result.PlaylistID, result.Kept, state.addedTo(result.PlaylistID))
+		if err != nil {
+			return result, fmt.Errorf("reading playlist: %w", err)
+		}
+		if err := removeFromPlaylist(ctx, accessToken, result.PlaylistID, result.Removed); err != nil {
+			return result, fmt.Errorf("pruning playlist: %w", err)
+		}
+		state.forgetAdded(result.PlaylistID, result.Removed)
+	}
+
+	// Keep the description up to date according to the policy
+	if err := applyDescriptionPolicy(ctx, accessToken, result, clock, opts); err != nil {
+		return result, fmt.Errorf("updating playlist description: %w", err)
+	}
+
+	// Keep the skipped songs in the companion playlist, if requested
+	if opts.CollectSkipped && len(result.Skipped) > 0 {
+		skippedName, skippedTitle := skippedPlaylistName(name), skippedPlaylistName(result.PlaylistName)
+		skippedPlaylistID, err := resolvePlaylist(ctx, accessToken, state, skippedName, skippedTitle, skippedPlaylistDescription, opts.OnDeletedPlaylist, false)
+		if err != nil {
+			return result, fmt.Errorf("finding skipped playlist: %w", err)
+		}
+		if _, err := addSongToPlaylist(ctx, accessToken, skippedPlaylistID, skippedTracks(result.Skipped), opts.Prepend); err != nil {
+			return result, fmt.Errorf("adding song to skipped playlist: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// Function to record in the state the tracks the sync added: the source of those from the
+// plugins and, for prune, the liked songs
+func recordAdditions(state *syncState, result syncResult) {
+	var likedAdded []Track
+	for _, track := range result.Added {
+		if source := result.source(track); source != provenanceLikedSong {
+			state.setProvenance(track.ID, source)
+		} else {
+			likedAdded = append(likedAdded, track)
+		}
+	}
+	state.recordAdded(result.PlaylistID, likedAdded)
+}
+
+// Function to list the tracks of a playlist missing from the kept tracks. Only liked songs the
+// tool added, as recorded in the state, by the syncing user are candidates: the tracks added by
+// hand or by collaborators are never removed.
+func staleTracks(ctx context.Context, accessToken, playlistID string, kept []Track, added map[string]bool) ([]Track, error) {
+	if playlistID == "" || len(added) == 0 {
+		return nil, nil
+	}
+	profile, err := getCurrentUser(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("getting current user: %w", err)
+	}
+	own, err := tracksAddedBy(ctx, accessToken, playlistID, profile.ID)
+	if err != nil {
+		return nil, err
+	}
+	keep := map[string]bool{}
+	for _, track := range kept {
+		keep[track.ID] = true
+	}
+	var stale []Track
+	for _, track := range own {
+		if added[track.ID] && !keep[track.ID] {
+			stale = append(stale, track)
+		}
+	}
+	return stale, nil
+}
+
+// Function to list the tracks of a playlist added by the given user, once each. The items added
+// by other users are collaborative edits, which the tool never removes.
+func tracksAddedBy(ctx context.Context, accessToken, playlistID, userID string) ([]Track, error) {
+	items, err := getPlaylistItems(ctx, accessToken, playlistID, pruneFields)
+	if err != nil {
+		return nil, err
+	}
+	var tracks []Track
+	seen := map[string]bool{}
+	for _, item := range items {
+		// Local files and unavailable tracks have no ID and are left alone
+		track := item.Track
+		if track.ID != "" && item.AddedBy.ID == userID && !seen[track.ID] {
+			seen[track.ID] = true
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, nil
+}
+
+// Function to notify that a month closed, summarizing its finished playlist. The summary is built
+// from the synced tracks rather than by reading the playlist back, the ones known only by ID
+// being looked up in the track cache, then the API.
+func finalizeMonth(ctx context.Context, accessToken, owner string, cache *trackCache, result syncResult, notifiers []notifier) {
+	if len(notifiers) == 0 {
+		return
+	}
+	failed := make(map[string]bool, len(result.Failed))
+	for _, track := range result.Failed {
+		failed[track.ID] = true
+	}
+	tracks := make([]Track, 0, len(result.Kept))
+	for _, track := range result.Kept {
+		if !failed[track.ID] {
+			tracks = append(tracks, track)
+		}
+	}
+	tracks, err := enrichTracks(ctx, accessToken, cache, tracks)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not summarize the finished playlist", "playlist", result.PlaylistName, "playlist_id", result.PlaylistID, "error", err)
+		return
+	}
+	sendNotification(ctx, notifiers, finalizationNotification(ownedPlaylistName(owner, result.PlaylistName), result.PlaylistID, tracks))
+}
+
+// Function to check that the added tracks are in the playlist, retrying the missing ones once.
+// It returns the tracks that were verified and the ones that could not be added.
+func verifyAdded(ctx context.Context, accessToken, playlistID string, added []Track, prepend bool) ([]Track, []Track, error) {
+	if len(added) == 0 {
+		return added, nil, nil
+	}
+
+	missing, err := missingFromPlaylist(ctx, accessToken, playlistID, added)
+	if err != nil || len(missing) == 0 {
+		return added, nil, err
+	}
+
+	slog.WarnContext(ctx, "Added tracks are missing from the playlist; adding them again", "playlist_id", playlistID, "missing", len(missing))
+	if _, err := addSongToPlaylist(ctx, accessToken, playlistID, missing, prepend); err != nil {
+		return nil, nil, err
+	}
+	failed, err := missingFromPlaylist(ctx, accessToken, playlistID, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	failedIDs := make(map[string]bool, len(failed))
+	for _, track := range failed {
+		slog.WarnContext(ctx, "The track could not be added to the playlist", "playlist_id", playlistID, "track_id", track.ID, "track", track.Name, "artists", artistNames(track))
+		failedIDs[track.ID] = true
+	}
+	verified := make([]Track, 0, len(added))
+	for _, track := range added {
+		if !failedIDs[track.ID] {
+			verified = append(verified, track)
+		}
+	}
+	return verified, failed, nil
+}
+
+// Positions where new tracks are added
+const (
+	positionAppend  = "append"
+	positionPrepend = "prepend"
+)
+
+// Policies for the description of existing playlists
+const (
+	descriptionNever   = "never"
+	descriptionReplace = "replace"
+	descriptionAppend  = "append"
+)
+
+// Function to update the description of the synced playlist: replaced by the configured one,
+// or with a dated line appended when tracks were added, so manual edits are kept
+func applyDescriptionPolicy(ctx context.Context, accessToken string, result syncResult, clock Clock, opts syncOptions) error {
+	if opts.DescriptionPolicy == descriptionNever {
+		return nil
+	}
+
+	current, err := getPlaylistDescription(ctx, accessToken, result.PlaylistID)
+	if err != nil {
+		return err
+	}
+
+	updated := opts.Description
+	if opts.DescriptionPolicy == descriptionAppend {
+		if len(result.Added) == 0 {
+			return nil
+		}
+		line := fmt.Sprintf("%s: %s (+%d)", clock.Now().Format("2006-01-02"), opts.Description, len(result.Added))
+		updated = appendDescriptionLine(current, line)
+	}
+	if sanitizePlaylistDescription(updated) == current {
+		return nil
+	}
+	return updatePlaylistDescription(ctx, accessToken, result.PlaylistID, updated)
+}
+
+// Separator of the lines of an appended description
+const descriptionSeparator = " · "
+
+// Function to append a line to a description, dropping its oldest lines until it fits within
+// what Spotify allows, so the new line is never the one cut off
+func appendDescriptionLine(current, line string) string {
+	var lines []string
+	if current = strings.TrimSpace(current); current != "" {
+		lines = strings.Split(current, descriptionSeparator)
+	}
+	lines = append(lines, line)
+	for len(lines) > 1 && utf8.RuneCountInString(strings.Join(lines, descriptionSeparator)) > maxPlaylistDescriptionLength {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, descriptionSeparator)
+}
+
+// Policies for managed playlists that were deleted in Spotify
+const (
+	deletedPlaylistRecreate = "recreate"
+	deletedPlaylistAbort    = "abort"
+)
+
+// Function to get the managed playlist with the given name: the one recorded in the state when it still exists,
+// otherwise an existing or new playlist titled title, which is then recorded. With a naming template the recorded
+// playlist is renamed when its title changed. A dry run only reports the playlist it would create, returning an
+// empty ID.
+func resolvePlaylist(ctx context.Context, accessToken string, state *syncState, name, title, description, onDeleted string, dryRun bool) (string, error) {
+	if playlistID := state.Playlists[name]; playlistID != "" {
+		playlist, err := apiClient(accessToken).Playlist(ctx, playlistID, "id,name")
+		if err != nil && !isNotFound(err) {
+			return "", err
+		}
+		if err == nil {
+			// Compared as created, a title too long or with control characters being cleaned up
+			if want := sanitizePlaylistName(title); playlistNameTemplate != nil && !playlistNamesMatch(playlist.Name, want) {
+				if dryRun {
+					fmt.Printf("Dry run: would rename %s to %s.\n", playlist.Name, want)
+				} else if err := updatePlaylistName(ctx, accessToken, playlistID, want); err != nil {
+					return "", fmt.Errorf("renaming %s: %w", playlist.Name, err)
+				}
+			}
+			return playlistID, nil
+		}
+
+		slog.WarnContext(ctx, "The playlist was deleted in Spotify", "playlist", name, "playlist_id", playlistID)
+		delete(state.Playlists, name)
+		if onDeleted == deletedPlaylistAbort {
+			return "", fmt.Errorf("the playlist %s was deleted; run with --on-deleted-playlist %s to recreate it", name, deletedPlaylistRecreate)
+		}
+	}
+
+	if dryRun {
+		playlist, found, err := searchPlaylist(ctx, accessToken, title)
+		if err == nil && !found {
+			fmt.Printf("Dry run: would create the playlist %s.\n", title)
+		}
+		return playlist.ID, err
+	}
+
+	playlistID, err := findOrCreatePlaylist(ctx, accessToken, title, description)
+	if err != nil {
+		return "", err
+	}
+	state.setPlaylist(name, playlistID)
+	return playlistID, nil
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long an onboarding link's trip to Spotify's consent page may take
+const onboardingTTL = 10 * time.Minute
+
+// Function to manage the tenants of the multi-tenant mode and serve them
+func runTenant(ctx context.Context, args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: spotify-cli tenant add|list|pause|resume|remove|login|serve [flags]")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("tenant "+args[0], flag.ExitOnError)
+	flags.StringVar(&tenantsDir, "tenants-dir", tenantsDir, "directory holding the tenants, one directory each")
+	publicURL := flags.String("public-url", "http://127.0.0.1:8090", "URL the onboarding server is reached at; <url>/callback must be a redirect URI of the app")
+
+	switch args[0] {
+	case "add":
+		name := flags.String("name", "", "name of the user, shown in the tenant list")
+		every := flags.String("every", "", "time between two syncs of the tenant, the serve default when empty")
+		cron := flags.String("cron", "", "cron expression of the tenant's sync times, instead of --every")
+		nameTemplate := flags.String("name-template", "", "naming template of the tenant's playlists, like the global --name-template; the tenant picks one when onboarding")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			fmt.Println("Usage: spotify-cli tenant add [flags] <tenant ID>")
+			os.Exit(2)
+		}
+		link, err := addTenant(tenant{ID: flags.Arg(0), Name: *name, Every: *every, Cron: *cron, NameTemplate: *nameTemplate}, *publicURL)
+		if err != nil {
+			fmt.Println("Error adding tenant:", err)
+			return
+		}
+		fmt.Printf("Added the tenant %s. Send them this onboarding link, valid until they use it:\n%s\n", flags.Arg(0), link)
+		fmt.Printf("Or authorize from this machine with: spotify-cli tenant login %s\n", flags.Arg(0))
+	case "list":
+		format := flags.String("format", "table", "output format: "+formatNames())
+		tmpl := flags.String("template", "", "Go template used by --format template")
+		flags.Parse(args[1:])
+		tenants, err := listTenants()
+		if err != nil {
+			fmt.Println("Error listing tenants:", err)
+			return
+		}
+		if err := writeReport(os.Stdout, *format, *tmpl, tenantReport(tenants)); err != nil {
+			fmt.Println("Error writing tenants:", err)
+		}
+	case "remove":
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			fmt.Println("Usage: spotify-cli tenant remove <tenant ID>")
+			os.Exit(2)
+		}
+		t, err := loadTenant(flags.Arg(0))
+		if err != nil {
+			fmt.Println("Error removing tenant:", err)
+			return
+		}
+		if err := os.RemoveAll(t.path("")); err != nil {
+			fmt.Println("Error removing tenant:", err)
+			return
+		}
+		fmt.Printf("Removed the tenant %s; their playlists are left in Spotify.\n", t.ID)
+	case "pause", "resume":
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			fmt.Printf("Usage: spotify-cli tenant %s <tenant ID>\n", args[0])
+			os.Exit(2)
+		}
+		t, err := loadTenant(flags.Arg(0))
+		if err == nil {
+			t.Paused = args[0] == "pause"
+			err = saveTenant(t)
+		}
+		if err != nil {
+			fmt.Printf("Error: could not %s the tenant: %v\n", args[0], err)
+			return
+		}
+		fmt.Printf("The tenant %s is %s; a running tenant serve picks it up at its next check.\n", t.ID, t.status())
+	case "login":
+		port := flags.Int("port", 8888, "local port of the callback; http://127.0.0.1:<port>/callback must be a redirect URI of the app")
+		noBrowser := flags.Bool("no-browser", false, "only print the authorization URL instead of opening it")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			fmt.Println("Usage: spotify-cli tenant login [flags] <tenant ID>")
+			os.Exit(2)
+		}
+		t, err := loadTenant(flags.Arg(0))
+		if err == nil {
+			_, err = loadTenantKey()
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		refreshToken, err := login(ctx, loadApps()[0], requiredScopes(syncFeatures()...), *port, !*noBrowser)
+		if err == nil {
+			err = onboardTenant(t, refreshToken)
+		}
+		if err != nil {
+			fmt.Println("Error logging in:", err)
+			return
+		}
+		fmt.Printf("Logged in the tenant %s.\n", t.ID)
+	case "serve":
+		addr := flags.String("addr", ":8090", "address the onboarding server listens on")
+		df := registerDaemonFlags(flags)
+		sf := registerSyncFlags(flags)
+		flags.Parse(args[1:])
+		fallback, err := df.schedule(flags, systemClock{})
+		if err != nil {
+			fmt.Println("Error", err)
+			return
+		}
+		serveTenants(ctx, *addr, *publicURL, fallback, sf)
+	default:
+		fmt.Println("Usage: spotify-cli tenant add|list|pause|resume|remove|login|serve [flags]")
+		os.Exit(2)
+	}
+}
+
+// Function to add a tenant, returning the onboarding link they authorize the app with
+func addTenant(t tenant, publicURL string) (string, error) {
+	if err := checkTenantID(t.ID); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(t.path("tenant.json")); err == nil {
+		return "", fmt.Errorf("the tenant %s already exists", t.ID)
+	}
+	if _, err := t.schedule(intervalSchedule{every: time.Hour}); err != nil {
+		return "", err
+	}
+	if _, err := parseNameTemplate(t.NameTemplate, playlistNameLocale); err != nil {
+		return "", fmt.Errorf("parsing --name-template: %w", err)
+	}
+	t.CreatedAt = time.Now()
+
+	code := make([]byte, 16)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	invite := hex.EncodeToString(code)
+	t.InviteHash = inviteHash(invite)
+	if err := saveTenant(t); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(publicURL, "/") + "/onboard/" + t.ID + "?" + url.Values{"invite": {invite}}.Encode(), nil
+}
+
+func inviteHash(invite string) string {
+	sum := sha256.Sum256([]byte(invite))
+	return hex.EncodeToString(sum[:])
+}
+
+// Function to store the refresh token a tenant authorized the app with, which closes their
+// onboarding link
+func onboardTenant(t tenant, refreshToken string) error {
+	if err := saveTenantToken(t, refreshToken); err != nil {
+		return fmt.Errorf("saving the token: %w", err)
+	}
+	t.InviteHash = ""
+	t.OnboardedAt = time.Now()
+	return saveTenant(t)
+}
+
+func tenantReport(tenants []tenant) report {
+	var rows [][]string
+	for _, t := range tenants {
+		lastSync := "never"
+		if state, err := loadState(t.path("state.json")); err == nil {
+			lastSync = formatStateTime(state.LastSyncedAt)
+		}
+		rows = append(rows, []string{t.ID, t.Name, t.status(), t.scheduleText(), lastSync})
+	}
+	return report{Columns: []string{"ID", "NAME", "STATUS", "SCHEDULE", "LAST_SYNC"}, Rows: rows, Data: tenants}
+}
+
+// An onboarding on its way through Spotify's consent page, keyed by its OAuth state
+type pendingOnboarding struct {
+	tenantID     string
+	nameTemplate string
+	verifier     string
+	expires      time.Time
+}
+
+// Server onboarding the tenants with the authorization code flow of the shared app
+type onboardingServer struct {
+	app         *spotifyApp
+	redirectURI string
+	// Password of the admin pages, which are disabled without one
+	adminPassword string
+	// Signaled when a tenant is onboarded, paused or resumed, so the scheduler doesn't wait to
+	// take the change into account
+	changed chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]pendingOnboarding
+}
+
+func (s *onboardingServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /onboard/{id}", s.handleOnboardPage)
+	mux.HandleFunc("POST /onboard/{id}", s.handleOnboard)
+	mux.HandleFunc("GET /callback", s.handleCallback)
+	mux.HandleFunc("GET /admin", s.admin(s.handleAdmin))
+	mux.HandleFunc("GET /admin/tenants/{id}", s.admin(s.handleAdminTenant))
+	mux.HandleFunc("POST /admin/tenants/{id}/{action}", s.admin(s.handleAdminAction))
+	// The metrics name the tenants, so they're behind the admin password too
+	mux.HandleFunc("GET /metrics", s.admin(handleMetrics))
+	return mux
+}
+
+// Function to register an onboarding and get the URL of Spotify's consent page it goes through
+func (s *onboardingServer) startAuthorization(t tenant, nameTemplate string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(nonce)
+	verifier, err := pkceVerifier()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	for key, p := range s.pending {
+		if time.Now().After(p.expires) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[state] = pendingOnboarding{tenantID: t.ID, nameTemplate: nameTemplate, verifier: verifier, expires: time.Now().Add(onboardingTTL)}
+	s.mu.Unlock()
+
+	query := url.Values{
+		"client_id":     {s.app.ClientID},
+		"response_type": {"code"},
+		"redirect_uri":  {s.redirectURI},
+		"state":         {state},
+		"scope":         {strings.Join(requiredScopes(syncFeatures()...), " ")},
+	}
+	if s.app.ClientSecret == "" {
+		query.Set("code_challenge_method", "S256")
+		query.Set("code_challenge", pkceChallenge(verifier))
+	}
+	return authorizeURL + "?" + query.Encode(), nil
+}
+
+// Function to complete an onboarding when Spotify redirects back with the authorization code
+func (s *onboardingServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	p, ok := s.pending[r.URL.Query().Get("state")]
+	delete(s.pending, r.URL.Query().Get("state"))
+	s.mu.Unlock()
+	if !ok || time.Now().After(p.expires) {
+		renderOnboardingPage(w, http.StatusBadRequest, onboardingPageData{Title: "Expired", Message: "This authorization is unknown or expired; open the onboarding link again."})
+		return
+	}
+	if reason := r.URL.Query().Get("error"); reason != "" {
+		renderOnboardingPage(w, http.StatusOK, onboardingPageData{Title: "Not connected", Message: "The authorization was denied; the onboarding link still works if you change your mind."})
+		slog.Info("The tenant denied the authorization", "tenant", p.tenantID, "reason", reason)
+		return
+	}
+
+	t, err := loadTenant(p.tenantID)
+	if err != nil {
+		renderOnboardingPage(w, http.StatusNotFound, onboardingPageData{Title: "Invalid link", Message: "This onboarding link is invalid or was already used."})
+		return
+	}
+	t.NameTemplate = p.nameTemplate
+	token, err := exchangeAuthorizationCode(r.Context(), s.app.ClientID, s.app.ClientSecret, r.URL.Query().Get("code"), s.redirectURI, p.verifier)
+	if err == nil {
+		err = onboardTenant(t, token.RefreshToken)
+	}
+	if err != nil {
+		slog.Error("Could not onboard the tenant", "tenant", t.ID, "error", err)
+		renderOnboardingPage(w, http.StatusBadGateway, onboardingPageData{Title: "Something went wrong", Message: "The authorization could not be completed; open the onboarding link again."})
+		return
+	}
+	slog.Info("Onboarded the tenant", "tenant", t.ID)
+	renderOnboardingPage(w, http.StatusOK, onboardingPageData{Title: "You're all set", Message: "Your first playlist is being filled now and kept current from here on. You can close this tab."})
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Function to serve the onboarding and sync every onboarded tenant on its schedule. The runs take
+// turns, since a run routes the API calls through the account it syncs.
+func serveTenants(ctx context.Context, addr, publicURL string, fallback schedule, sf *syncFlags) {
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if _, err := loadTenantKey(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	app := loadApps()[0]
+	if app.ClientID == "" {
+		fmt.Printf("Error: set the client ID of the shared app in %s first\n", envFile)
+		return
+	}
+
+	server := &onboardingServer{
+		app:           app,
+		redirectURI:   strings.TrimSuffix(publicURL, "/") + "/callback",
+		changed:       make(chan struct{}, 1),
+		adminPassword: os.Getenv(tenantAdminPasswordEnv),
+		pending:       map[string]pendingOnboarding{},
+	}
+	if tenants, err := listTenants(); err == nil {
+		for _, t := range tenants {
+			if err := seedSyncMetrics(t.ID, t.path("history.jsonl")); err != nil {
+				slog.Warn("Could not read the run history", "tenant", t.ID, "error", err)
+			}
+		}
+	}
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		slog.Info("Onboarding tenants; the redirect URI must be one of the app", "addr", addr, "redirect_uri", server.redirectURI)
+		if err := serve(ctx, addr, server.handler()); err != nil {
+			slog.Error("The onboarding server stopped", "error", err)
+		}
+	}()
+
+	// Runs are let finish on the first interrupt, like in the daemon
+	runCtx := context.WithoutCancel(ctx)
+	next := map[string]time.Time{}
+	for ctx.Err() == nil {
+		tenants, err := listTenants()
+		if err != nil {
+			slog.Error("Could not list the tenants", "error", err)
+		}
+		wake := time.Now().Add(time.Minute)
+		for _, t := range tenants {
+			if t.status() != "active" || ctx.Err() != nil {
+				continue
+			}
+			sched, err := t.schedule(fallback)
+			if err != nil {
+				slog.Warn("Skipping the tenant", "tenant", t.ID, "error", err)
+				continue
+			}
+			// New tenants are synced right away
+			at, ok := next[t.ID]
+			if !ok || !at.After(time.Now()) {
+				syncTenant(runCtx, opts, *sf.cacheTTL, t)
+				at = sched.Next(nowInMonthZone())
+			}
+			next[t.ID] = at
+			if at.Before(wake) {
+				wake = at
+			}
+		}
+		select {
+		case <-time.After(time.Until(wake)):
+		case <-server.changed:
+		case <-ctx.Done():
+		}
+	}
+	<-served
+	slog.Info("Stopped serving the tenants")
+}
+
+// Function to run the sync of one tenant with its own state and credentials
+func syncTenant(ctx context.Context, opts syncOptions, cacheTTL time.Duration, t tenant) {
+	ctx = withTenant(ctx, t)
+	opts, err := t.options(opts, cacheTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not sync the tenant", "error", err)
+		return
+	}
+	restore, err := t.applyNaming()
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not sync the tenant", "error", err)
+		return
+	}
+	defer restore()
+	slog.InfoContext(ctx, "Syncing the tenant")
+	summary, err := runSyncWithHooks(ctx, opts)
+	ctx = withCorrelationID(ctx, summary.RunID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Run failed", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Run finished", "tracks_added", summary.TracksAdded)
+}
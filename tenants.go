@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory of the tenants synced by tenant serve, set with --tenants-dir. Each tenant has a
+// directory of its own holding its settings, its encrypted refresh token and its state, run
+// history and track cache, so the tenants never see each other's data.
+var tenantsDir = filepath.Join(filepath.Dir(defaultStatePath()), "tenants")
+
+// Environment variable holding the key the refresh tokens of the tenants are encrypted with:
+// 32 random bytes in base64, e.g. from openssl rand -base64 32
+const tenantKeyEnv = "SPOTIFY_TENANT_KEY"
+
+// IDs of the tenants, which name their directories
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// A user synced by the multi-tenant mode with the shared Spotify app
+type tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Schedule of the tenant's syncs, an interval like 6h or a cron expression; the serve
+	// defaults when both are empty
+	Every string `json:"every,omitempty"`
+	Cron  string `json:"cron,omitempty"`
+	// Naming template of the tenant's playlists, picked when onboarding; --name-template when empty
+	NameTemplate string `json:"name_template,omitempty"`
+	// Hash of the invite code of the onboarding link, cleared once the tenant authorized the app
+	InviteHash string `json:"invite_hash,omitempty"`
+	// Whether the tenant's syncs are suspended, from the admin page or tenant pause
+	Paused      bool      `json:"paused,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	OnboardedAt time.Time `json:"onboarded_at"`
+}
+
+type tenantKey struct{}
+
+func withTenant(ctx context.Context, t tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// Function to get the tenant a context syncs, in the multi-tenant mode
+func tenantFromContext(ctx context.Context) (tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(tenant)
+	return t, ok
+}
+
+// Function to get the location of one of the tenant's files
+func (t tenant) path(name string) string {
+	return filepath.Join(tenantsDir, t.ID, name)
+}
+
+func (t tenant) onboarded() bool {
+	return !t.OnboardedAt.IsZero()
+}
+
+func checkTenantID(id string) error {
+	if !tenantIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid tenant ID %q: use up to 32 lowercase letters, digits and dashes", id)
+	}
+	return nil
+}
+
+func loadTenant(id string) (tenant, error) {
+	var t tenant
+	if err := checkTenantID(id); err != nil {
+		return t, err
+	}
+	data, err := os.ReadFile(tenant{ID: id}.path("tenant.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return t, fmt.Errorf("unknown tenant %q", id)
+		}
+		return t, err
+	}
+	err = json.Unmarshal(data, &t)
+	return t, err
+}
+
+func saveTenant(t tenant) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(tenantsDir, t.ID), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(t.path("tenant.json"), append(data, '\n'), 0o600)
+}
+
+// Function to list the tenants, sorted by ID
+func listTenants() ([]tenant, error) {
+	entries, err := os.ReadDir(tenantsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var tenants []tenant
+	for _, entry := range entries {
+		if !entry.IsDir() || checkTenantID(entry.Name()) != nil {
+			continue
+		}
+		t, err := loadTenant(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("loading tenant %s: %w", entry.Name(), err)
+		}
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+// Function to read the key encrypting the refresh tokens of the tenants
+func loadTenantKey() ([]byte, error) {
+	encoded := os.Getenv(tenantKeyEnv)
+	if encoded == "" {
+		return nil, fmt.Errorf("%s is not set; generate one with openssl rand -base64 32", tenantKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes in base64", tenantKeyEnv)
+	}
+	return key, nil
+}
+
+// Function to encrypt a secret of the tenant with AES-GCM, bound to the tenant's ID so it can't
+// be moved to another tenant
+func (t tenant) seal(key []byte, secret string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), []byte(t.ID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (t tenant) open(key []byte, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("the encrypted token is truncated")
+	}
+	secret, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(t.ID))
+	if err != nil {
+		return "", errors.New("the token can't be decrypted; was the key changed?")
+	}
+	return string(secret), nil
+}
+
+// Function to store the tenant's refresh token, encrypted
+func saveTenantToken(t tenant, refreshToken string) error {
+	key, err := loadTenantKey()
+	if err != nil {
+		return err
+	}
+	sealed, err := t.seal(key, refreshToken)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(tenantsDir, t.ID), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(t.path("token.enc"), []byte(sealed+"\n"), 0o600)
+}
+
+// Function to read back the tenant's refresh token
+func loadTenantToken(t tenant) (string, error) {
+	key, err := loadTenantKey()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(t.path("token.enc"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("the tenant %s hasn't authorized the app yet", t.ID)
+		}
+		return "", err
+	}
+	return t.open(key, strings.TrimSpace(string(data)))
+}
+
+// Function to get the app a tenant is synced through: the primary app of the env file with the
+// tenant's refresh token, a rotated one being saved back encrypted
+func tenantApps(t tenant) ([]*spotifyApp, error) {
+	refreshToken, err := loadTenantToken(t)
+	if err != nil {
+		return nil, err
+	}
+	shared := loadApps()[0]
+	app := &spotifyApp{
+		Name:         "tenant " + t.ID,
+		ClientID:     shared.ClientID,
+		ClientSecret: shared.ClientSecret,
+		RefreshToken: refreshToken,
+		tenant:       t.ID,
+		saveRefreshToken: func(refreshToken string) error {
+			return saveTenantToken(t, refreshToken)
+		},
+	}
+	return []*spotifyApp{app}, nil
+}
+
+// Function to get the tenant's schedule, the given default when it has none of its own
+func (t tenant) schedule(fallback schedule) (schedule, error) {
+	if t.Every == "" && t.Cron == "" {
+		return fallback, nil
+	}
+	var every time.Duration
+	if t.Every != "" {
+		var err error
+		if every, err = time.ParseDuration(t.Every); err != nil {
+			return nil, fmt.Errorf("parsing the interval of %s: %w", t.ID, err)
+		}
+	}
+	return parseSchedule(t.Cron, every, systemClock{})
+}
+
+// Function to name the playlists of the tenant's run with its naming template, returning the
+// function putting the global one back. Only one run goes at a time, so the global can be swapped.
+func (t tenant) applyNaming() (func(), error) {
+	previous := playlistNameTemplate
+	if t.NameTemplate == "" {
+		return func() {}, nil
+	}
+	tmpl, err := parseNameTemplate(t.NameTemplate, playlistNameLocale)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the naming template of %s: %w", t.ID, err)
+	}
+	playlistNameTemplate = tmpl
+	return func() { playlistNameTemplate = previous }, nil
+}
+
+// Function to point the sync options at the tenant's own state, history, cache and lock
+func (t tenant) options(opts syncOptions, cacheTTL time.Duration) (syncOptions, error) {
+	cache, err := loadTrackCache(t.path("cache.json"), cacheTTL)
+	if err != nil {
+		return opts, fmt.Errorf("loading the track cache of %s: %w", t.ID, err)
+	}
+	opts.Cache = cache
+	opts.StatePath = t.path("state.json")
+	opts.HistoryPath = t.path("history.jsonl")
+	opts.LockPath = t.path("sync.lock")
+	opts.ShortlistPath = ""
+	opts.OutputPath = ""
+	return opts, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// Env file holding the credentials, set with --config
+var envFile = ".env.local"
+
+// Function to get an access token from the configured apps, routing the Web API calls through them
+func authenticate(ctx context.Context) (AccessTokenResponse, *appPool, error) {
+	apps := loadApps()
+	if t, ok := tenantFromContext(ctx); ok {
+		var err error
+		if apps, err = tenantApps(t); err != nil {
+			return AccessTokenResponse{}, nil, err
+		}
+	}
+	pool := newAppPool(apps, baseTransport)
+	token, err := pool.authenticate(ctx)
+	if err != nil {
+		return token, nil, err
+	}
+	pool.runToken = token.AccessToken
+	runClientsMu.Lock()
+	entry := runClients[token.AccessToken]
+	if entry == nil {
+		entry = &runClientEntry{client: &http.Client{Transport: pool}}
+		runClients[token.AccessToken] = entry
+	}
+	entry.runs++
+	runClientsMu.Unlock()
+	return token, pool, nil
+}
+
+// Clients of the runs, keyed by the access token they authenticated with, each calling the Web
+// API through the app pool of its run so concurrent runs of the tenants never share one. The
+// runs authenticating with the same cached token share its entry, dropped once they all ended.
+var (
+	runClientsMu sync.Mutex
+	runClients   = map[string]*runClientEntry{}
+)
+
+type runClientEntry struct {
+	client *http.Client
+	runs   int
+}
+
+// Function to get the client of the run that authenticated with the access token, the shared
+// one for a token from elsewhere
+func runClient(accessToken string) *http.Client {
+	runClientsMu.Lock()
+	defer runClientsMu.Unlock()
+	if entry, ok := runClients[accessToken]; ok {
+		return entry.client
+	}
+	return httpClient
+}
+
+// Function to forget the client of a run that ended
+func forgetRunClient(accessToken string) {
+	runClientsMu.Lock()
+	defer runClientsMu.Unlock()
+	if entry, ok := runClients[accessToken]; ok {
+		if entry.runs--; entry.runs <= 0 {
+			delete(runClients, accessToken)
+		}
+	}
+}
+
+// Function to store a new refresh token in the env file, keeping backups of the previous files.
+// The file is replaced atomically so a crash never leaves it half written, and created when missing.
+func persistRefreshToken(path, key, refreshToken string) error {
+	env, err := godotenv.Read(path)
+	if os.IsNotExist(err) {
+		env, err = map[string]string{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	env[key] = refreshToken
+
+	content, err := godotenv.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	return writeFileWithBackups(path, []byte(content+"\n"), 0o600)
+}
+
+// Number of rotated backups kept of the state and env files, set with --backups
+var backupCount = 3
+
+// Function to replace a file atomically, first rotating its previous versions into
+// path.1 (the newest) to path.N, dropping the oldest
+func writeFileWithBackups(path string, data []byte, perm os.FileMode) error {
+	if backupCount > 0 {
+		if _, err := os.Stat(path); err == nil {
+			for i := backupCount - 1; i >= 1; i-- {
+				older := fmt.Sprintf("%s.%d", path, i)
+				if err := os.Rename(older, fmt.Sprintf("%s.%d", path, i+1)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			// Copied rather than renamed so the file is never missing if the write fails
+			if err := copyFile(path, path+".1"); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomic(path, data, perm)
+}
+
+// Function to write a file through a temporary file and a rename
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data, 0o600)
+}
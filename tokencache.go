@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Local cache of the access tokens, so runs reuse the token of the previous one until it's
+// about to expire; set with --token-cache, empty to disable
+var tokenCachePath = filepath.Join(filepath.Dir(defaultStatePath()), "tokens.json")
+
+// How long before its expiry a cached access token is refreshed, set with --token-refresh-margin
+var tokenRefreshMargin = 5 * time.Minute
+
+// An access token as cached, keyed by the client ID of its app
+type cachedToken struct {
+	AccessToken string    `json:"access_token"`
+	Scope       string    `json:"scope"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// Hash of the refresh token it was obtained with, so a new authorization isn't shadowed
+	RefreshTokenHash string `json:"refresh_token_hash"`
+}
+
+func refreshTokenHash(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func loadTokenCache() map[string]cachedToken {
+	tokens := map[string]cachedToken{}
+	if tokenCachePath == "" {
+		return tokens
+	}
+	data, err := os.ReadFile(tokenCachePath)
+	if err != nil {
+		return tokens
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		slog.Warn("Ignoring the unreadable token cache", "path", tokenCachePath, "error", err)
+		return map[string]cachedToken{}
+	}
+	return tokens
+}
+
+func saveTokenCache(tokens map[string]cachedToken) {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(tokenCachePath), 0o700)
+	}
+	if err == nil {
+		err = writeFileAtomic(tokenCachePath, append(data, '\n'), 0o600)
+	}
+	if err != nil {
+		slog.Warn("Could not save the token cache", "error", err)
+	}
+}
+
+// Function to get the cached access token of an app while it's valid for longer than the margin
+func cachedAccessToken(app *spotifyApp) (AccessTokenResponse, bool) {
+	// The tokens of the tenants are kept encrypted, so their access tokens never reach the disk
+	if app.tenant != "" {
+		return AccessTokenResponse{}, false
+	}
+	cached, ok := loadTokenCache()[app.ClientID]
+	remaining := time.Until(cached.ExpiresAt)
+	if !ok || cached.RefreshTokenHash != refreshTokenHash(app.RefreshToken) || remaining <= tokenRefreshMargin {
+		return AccessTokenResponse{}, false
+	}
+	return AccessTokenResponse{AccessToken: cached.AccessToken, Scope: cached.Scope, ExpiresIn: int(remaining.Seconds())}, true
+}
+
+// Function to cache a fresh access token of an app, obtained at the given time
+func cacheAccessToken(app *spotifyApp, token AccessTokenResponse, obtainedAt time.Time) {
+	if tokenCachePath == "" || app.tenant != "" {
+		return
+	}
+	tokens := loadTokenCache()
+	tokens[app.ClientID] = cachedToken{
+		AccessToken:      token.AccessToken,
+		Scope:            token.Scope,
+		ExpiresAt:        obtainedAt.Add(time.Duration(token.ExpiresIn) * time.Second),
+		RefreshTokenHash: refreshTokenHash(app.RefreshToken),
+	}
+	saveTokenCache(tokens)
+}
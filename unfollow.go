@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Function to unfollow managed playlists, which deletes the ones the user owns, and drop them
+// from the state so the next sync doesn't expect them
+func runUnfollow(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("unfollow", flag.ExitOnError)
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping the state between runs")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: spotify-cli unfollow [flags] <playlist name or ID>...")
+		os.Exit(2)
+	}
+	if err := unfollowPlaylists(ctx, *statePath, flags.Args()); err != nil {
+		fmt.Println("Error unfollowing playlists:", err)
+	}
+}
+
+// Function to unfollow the managed playlists given by name or ID. Playlists the tool doesn't
+// manage are refused, so a typo can't delete one of the user's own playlists.
+// With --dry-run nothing is changed.
+func unfollowPlaylists(ctx context.Context, statePath string, targets []string) error {
+	state, err := loadState(statePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	selected := map[string]string{}
+	for _, target := range targets {
+		name, ok := managedPlaylistName(state, target)
+		if !ok {
+			return fmt.Errorf("%s is not a managed playlist; see state show", target)
+		}
+		selected[name] = state.Playlists[name]
+	}
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if dryRun {
+		for _, name := range names {
+			fmt.Printf("Dry run: would unfollow %s (%s).\n", name, selected[name])
+		}
+		return nil
+	}
+
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+
+	for _, name := range names {
+		if err := unfollowPlaylist(ctx, token.AccessToken, selected[name]); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unfollowing %s: %w", name, err)
+		}
+		delete(state.Playlists, name)
+		// Save after every playlist so a failure keeps the state in line with Spotify
+		if err := saveState(statePath, state); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		fmt.Printf("Unfollowed %s (%s).\n", name, selected[name])
+	}
+	return nil
+}
+
+// Function to find the state name of a managed playlist given by name or ID
+func managedPlaylistName(state syncState, target string) (string, bool) {
+	if _, ok := state.Playlists[target]; ok {
+		return target, true
+	}
+	for name, id := range state.Playlists {
+		if id == target {
+			return name, true
+		}
+	}
+	return "", false
+}
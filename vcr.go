@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// An HTTP interaction captured in a cassette
+type interaction struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body"`
+}
+
+// A transport recording the real API interactions into a cassette file, or replaying them from it,
+// so features can be developed and tested offline
+type vcrTransport struct {
+	mu           sync.Mutex
+	path         string
+	recording    bool
+	interactions []interaction
+	used         []bool
+	next         http.RoundTripper
+	// Placeholders of the user IDs met while recording, keyed by the real ID
+	users map[string]string
+}
+
+// Secrets never written to cassettes. Refresh tokens are emptied rather than redacted,
+// so replaying a token response is never mistaken for a token rotation.
+var (
+	cassetteSecrets       = regexp.MustCompile(`("access_token"\s*:\s*")[^"]*(")|((?:refresh_token|code_verifier|code)=)[^&]*`)
+	cassetteRefreshTokens = regexp.MustCompile(`("refresh_token"\s*:\s*")[^"]*(")`)
+)
+
+// Function to build the transport for SPOTIFY_VCR_MODE (record or replay) and SPOTIFY_VCR_CASSETTE
+func newVCRTransport(mode, path string, next http.RoundTripper) (*vcrTransport, error) {
+	t := &vcrTransport{path: path, next: next}
+	switch mode {
+	case "record":
+		t.recording = true
+		slog.Info("Recording the API interactions", "path", path)
+	case "replay":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &t.interactions); err != nil {
+			return nil, fmt.Errorf("reading cassette %s: %w", path, err)
+		}
+		t.used = make([]bool, len(t.interactions))
+		slog.Info("Replaying the API interactions", "path", path, "interactions", len(t.interactions))
+	default:
+		return nil, fmt.Errorf("unknown VCR mode %q, expected record or replay", mode)
+	}
+	return t, nil
+}
+
+// Objects of the API describing a user, the current one from /me and the others as the owners
+// of playlists or the adders of their tracks
+var userObjectKeys = map[string]bool{"owner": true, "added_by": true}
+
+func (t *vcrTransport) userAlias(id string) string {
+	if alias, ok := t.users[id]; ok {
+		return alias
+	}
+	if t.users == nil {
+		t.users = map[string]string{}
+	}
+	alias := fmt.Sprintf("cassette-user-%d", len(t.users)+1)
+	t.users[id] = alias
+	return alias
+}
+
+// Function to replace the ID, display name and email of the users in a JSON body with
+// placeholders, the same user always getting the same one so a replay sees the same owners
+func (t *vcrTransport) sanitizeUsers(path, body string) string {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	changed := false
+	var walk func(value any, isUser bool)
+	walk = func(value any, isUser bool) {
+		switch v := value.(type) {
+		case map[string]any:
+			if isUser {
+				if id, ok := v["id"].(string); ok && id != "" {
+					v["id"] = t.userAlias(id)
+					changed = true
+				}
+				for key, placeholder := range map[string]string{"display_name": "Cassette User", "email": "cassette-user@example.com"} {
+					if _, ok := v[key]; ok {
+						v[key] = placeholder
+						changed = true
+					}
+				}
+			}
+			for key, child := range v {
+				walk(child, userObjectKeys[key])
+			}
+		case []any:
+			for _, child := range v {
+				walk(child, false)
+			}
+		}
+	}
+	walk(value, path == "/v1/me")
+	if !changed {
+		return body
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
+
+// Function to replace the user IDs left in the URLs and URIs of an interaction, e.g. in
+// /users/{id}/playlists or the open.spotify.com/user/{id} links, once the user is known
+func (t *vcrTransport) replaceUserIDs(recorded interaction) interaction {
+	var pairs []string
+	for id, alias := range t.users {
+		pairs = append(pairs, "/users/"+id, "/users/"+alias, "/user/"+id, "/user/"+alias, "spotify:user:"+id, "spotify:user:"+alias)
+	}
+	replacer := strings.NewReplacer(pairs...)
+	recorded.URL = replacer.Replace(recorded.URL)
+	recorded.RequestBody = replacer.Replace(recorded.RequestBody)
+	recorded.Body = replacer.Replace(recorded.Body)
+	return recorded
+}
+
+func sanitizeCassette(value string) string {
+	value = cassetteSecrets.ReplaceAllString(value, "${1}${3}REDACTED${2}")
+	return cassetteRefreshTokens.ReplaceAllString(value, "${1}${2}")
+}
+
+func (t *vcrTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		requestBody = body
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if !t.recording {
+		return t.replay(req)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := http.Header{}
+	for _, key := range []string{"Content-Type", "Retry-After", "Location"} {
+		if value := resp.Header.Get(key); value != "" {
+			header.Set(key, value)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interactions = append(t.interactions, interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: sanitizeCassette(string(requestBody)),
+		Status:      resp.StatusCode,
+		Header:      header,
+		Body:        t.sanitizeUsers(req.URL.Path, sanitizeCassette(string(body))),
+	})
+	return resp, t.save()
+}
+
+// Function to answer with the first unused interaction recorded for the same method and URL
+func (t *vcrTransport) replay(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, recorded := range t.interactions {
+		if t.used[i] || recorded.Method != req.Method || recorded.URL != req.URL.String() {
+			continue
+		}
+		t.used[i] = true
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+			StatusCode: recorded.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     recorded.Header.Clone(),
+			Body:       io.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+			Request:    req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction left for %s %s", req.Method, req.URL)
+}
+
+// Function to write the cassette, the user IDs replaced in every interaction, including the
+// ones recorded before the user was met
+func (t *vcrTransport) save() error {
+	interactions := make([]interaction, len(t.interactions))
+	for i, recorded := range t.interactions {
+		interactions[i] = t.replaceUserIDs(recorded)
+	}
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(t.path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Function to poll the liked songs every few minutes, adding the new likes to their period's
+// playlist soon after they happen
+func runWatch(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("interval", 5*time.Minute, "time between two polls of the liked songs")
+	sf := registerSyncFlags(flags)
+	flags.Parse(args)
+
+	opts, err := sf.options()
+	if err != nil {
+		fmt.Println("Error", err)
+		return
+	}
+	if *interval < time.Minute {
+		fmt.Println("Error: --interval must be at least 1m")
+		return
+	}
+	// Only the new likes are synced, so every other track would look stale
+	opts.Prune = false
+
+	slog.Info("Watching the liked songs", "interval", *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := pollLikes(ctx, opts); err != nil && ctx.Err() == nil {
+			slog.Warn("Polling the liked songs failed", "error", explainError(err))
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Info("Watch stopped")
+			return
+		}
+	}
+}
+
+// Function to look for the songs liked since the watch cursor and sync them, between the hooks,
+// into the playlists of their periods. The first poll only sets the cursor to the newest like;
+// the earlier ones are left to sync.
+func pollLikes(ctx context.Context, opts syncOptions) error {
+	// The API call budget is per poll, the sync of the new likes having one of its own
+	ctx = withAPIBudget(ctx)
+	state, err := loadState(opts.StatePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	accessToken := token.AccessToken
+	if state.WatchCursor.IsZero() {
+		_, newest, err := probeLikedSongs(ctx, accessToken)
+		if err != nil {
+			return fmt.Errorf("probing liked songs: %w", err)
+		}
+		if newest.IsZero() || opts.DryRun {
+			return nil
+		}
+		state.WatchCursor = newest
+		slog.InfoContext(ctx, "Watching the songs liked from now on; run sync for the earlier ones", "since", newest.Format(time.RFC3339))
+		return saveState(opts.StatePath, state)
+	}
+
+	liked, err := fetchLikedSongs(ctx, accessToken, state.WatchCursor)
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	periods := map[time.Time][]LikedSong{}
+	for _, song := range liked.Items {
+		if song.AddedAt.After(state.WatchCursor) {
+			start := periodStart(song.AddedAt.In(monthZone))
+			periods[start] = append(periods[start], song)
+		}
+	}
+	if len(periods) == 0 {
+		return nil
+	}
+
+	perform := func(ctx context.Context, opts syncOptions, summary *runSummary) error {
+		return syncNewLikes(ctx, accessToken, opts, periods, summary)
+	}
+	_, err = runWithHooks(ctx, opts, perform)
+	return err
+}
+
+// Function to add the new likes of each period to its playlist, oldest period first, moving the
+// watch cursor past each period's likes once they're in
+func syncNewLikes(ctx context.Context, accessToken string, opts syncOptions, periods map[time.Time][]LikedSong, summary *runSummary) error {
+	if opts.LockPath != "" {
+		lock, err := acquireLock(opts.LockPath, opts.LockTTL)
+		if err != nil {
+			return fmt.Errorf("acquiring lock: %w", err)
+		}
+		defer lock.Release()
+	}
+	defer saveTrackCache(opts.Cache)
+
+	// Read again under the lock, a sync may have run since the poll
+	state, err := loadState(opts.StatePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	starts := make([]time.Time, 0, len(periods))
+	for start := range periods {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	for _, start := range starts {
+		songs := periods[start]
+		tracks := make([]Track, len(songs))
+		for i, song := range songs {
+			tracks[i] = song.Track
+		}
+		slog.InfoContext(ctx, "Found new likes", "playlist", periodPlaylistName(start), "songs", len(tracks))
+		result, err := syncTracks(ctx, accessToken, periodClock(start), tracks, opts, &state)
+		summary.add(result)
+		if err != nil {
+			return err
+		}
+		if opts.DryRun {
+			continue
+		}
+		slog.InfoContext(ctx, "Synced the playlist", "playlist", result.PlaylistName, "playlist_id", result.PlaylistID, "tracks_added", len(result.Added))
+
+		// The songs come newest first
+		if songs[0].AddedAt.After(state.WatchCursor) {
+			state.WatchCursor = songs[0].AddedAt
+		}
+		if err := saveState(opts.StatePath, state); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import "sync"
+
+// Concurrency limits, set with --page-workers and --enrich-workers. The defaults stay gentle,
+// for small machines and Spotify's rate limits.
+var (
+	pageWorkers   = 2
+	enrichWorkers = 2
+)
+
+// Function to call fn for 0 to n-1 with at most workers calls running at once, returning the
+// first error. After an error no new calls are started.
+func runConcurrently(workers, n int, fn func(i int) error) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	slots := make(chan struct{}, max(workers, 1))
+	for i := 0; i < n; i++ {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(i); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// Orders of the tracks of the year-in-review playlist
+const (
+	wrappedOrderRecency    = "recency"
+	wrappedOrderPopularity = "popularity"
+)
+
+// Provenance of the tracks read back from the period playlists
+const provenancePeriodPlaylist = "period-playlist"
+
+// Function to build the year-in-review playlist, e.g. Best of 2025, from the songs liked that year
+func runWrapped(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("wrapped", flag.ExitOnError)
+	year := flags.Int("year", nowInMonthZone().Year()-1, "year to review, last year by default")
+	name := flags.String("name", "Best of %d", "name of the playlist, %d standing for the year")
+	limit := flags.Int("limit", 0, "most tracks in the playlist, 0 for all of them")
+	order := flags.String("order", wrappedOrderRecency, "which tracks come first and are kept within --limit: recency (newest like first) or popularity")
+	dedupe := flags.String("dedupe", dedupeByID, "when two tracks are the same: id, or isrc to also merge different releases of a recording")
+	includePlaylists := flags.Bool("include-playlists", false, "also take the tracks of the year's managed playlists, e.g. the ones added by source plugins")
+	statePath := flags.String("state-file", defaultStatePath(), "file keeping the state between runs")
+	flags.Parse(args)
+
+	if *order != wrappedOrderRecency && *order != wrappedOrderPopularity {
+		fmt.Printf("Error parsing --order: must be %s or %s\n", wrappedOrderRecency, wrappedOrderPopularity)
+		return
+	}
+	if *dedupe != dedupeByID && *dedupe != dedupeByISRC {
+		fmt.Printf("Error parsing --dedupe: must be %s or %s\n", dedupeByID, dedupeByISRC)
+		return
+	}
+
+	opts := wrappedOptions{
+		Year:             *year,
+		Name:             fmt.Sprintf(*name, *year),
+		Limit:            *limit,
+		Order:            *order,
+		Dedupe:           *dedupe,
+		IncludePlaylists: *includePlaylists,
+		StatePath:        *statePath,
+	}
+	if err := buildWrapped(ctx, opts); err != nil {
+		fmt.Println("Error building the year in review:", explainError(err))
+	}
+}
+
+type wrappedOptions struct {
+	Year             int
+	Name             string
+	Limit            int
+	Order            string
+	Dedupe           string
+	IncludePlaylists bool
+	StatePath        string
+}
+
+// Function to gather the songs liked during the year, order and cap them, and make them the
+// contents of the year-in-review playlist, which is recorded in the state like the period ones.
+// With --dry-run the tracks are only printed.
+func buildWrapped(ctx context.Context, opts wrappedOptions) error {
+	state, err := loadState(opts.StatePath)
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+	token, apps, err := authenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token: %w", err)
+	}
+	defer apps.release()
+	accessToken := token.AccessToken
+
+	start := time.Date(opts.Year, time.January, 1, 0, 0, 0, 0, monthZone)
+	end := start.AddDate(1, 0, 0)
+	liked, err := fetchLikedSongs(ctx, accessToken, start)
+	if err != nil {
+		return fmt.Errorf("getting liked songs: %w", err)
+	}
+	addedAt := map[string]time.Time{}
+	groups := []sourceTracks{{Source: provenanceLikedSong}}
+	for _, song := range liked.Items {
+		if song.AddedAt.Before(start) || !song.AddedAt.Before(end) {
+			continue
+		}
+		groups[0].Tracks = append(groups[0].Tracks, song.Track)
+		addedAt[song.Track.ID] = song.AddedAt
+	}
+
+	if opts.IncludePlaylists {
+		group := sourceTracks{Source: provenancePeriodPlaylist}
+		for period := periodStart(start); period.Before(end); period = nextPeriod(period) {
+			playlistID := state.Playlists[periodPlaylistName(period)]
+			if playlistID == "" {
+				continue
+			}
+			items, err := getPlaylistItems(ctx, accessToken, playlistID, "")
+			if err != nil && !isNotFound(err) {
+				return fmt.Errorf("reading %s: %w", periodPlaylistName(period), err)
+			}
+			for _, item := range items {
+				group.Tracks = append(group.Tracks, item.Track)
+				if _, ok := addedAt[item.Track.ID]; !ok {
+					addedAt[item.Track.ID] = item.AddedAt
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	tracks, _ := mergeSources(groups, []string{provenanceLikedSong}, opts.Dedupe)
+	sort.SliceStable(tracks, func(i, j int) bool {
+		if opts.Order == wrappedOrderPopularity && tracks[i].Popularity != tracks[j].Popularity {
+			return tracks[i].Popularity > tracks[j].Popularity
+		}
+		return addedAt[tracks[i].ID].After(addedAt[tracks[j].ID])
+	})
+	if opts.Limit > 0 && len(tracks) > opts.Limit {
+		tracks = tracks[:opts.Limit]
+	}
+	if len(tracks) == 0 {
+		fmt.Printf("No songs were liked in %d.\n", opts.Year)
+		return nil
+	}
+
+	description := fmt.Sprintf("Songs liked in %d", opts.Year)
+	playlistID, err := resolvePlaylist(ctx, accessToken, &state, opts.Name, opts.Name, description, deletedPlaylistRecreate, dryRun)
+	if err != nil {
+		return fmt.Errorf("finding playlist: %w", err)
+	}
+	if dryRun {
+		printPlan(os.Stdout, opts.Name, tracks)
+		return nil
+	}
+	if _, err := apiClient(accessToken).ReplaceTracks(ctx, playlistID, nil); err != nil {
+		return fmt.Errorf("emptying %s: %w", opts.Name, err)
+	}
+	added, err := addSongToPlaylist(ctx, accessToken, playlistID, tracks, false)
+	if err != nil {
+		return fmt.Errorf("adding songs to %s: %w", opts.Name, err)
+	}
+	if err := saveState(opts.StatePath, state); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	slog.InfoContext(ctx, "Filled the playlist", "playlist", opts.Name, "tracks_added", len(added))
+	fmt.Printf("%s is ready: %s\n", opts.Name, playlistURL(playlistID))
+	return nil
+}